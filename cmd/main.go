@@ -1,16 +1,31 @@
 package main
 
 import (
+	"cmp"
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
+	"github.com/RajNykDhulapkar/gotiny/internals/blocklist"
 	"github.com/RajNykDhulapkar/gotiny/internals/cache"
 	"github.com/RajNykDhulapkar/gotiny/internals/data"
+	"github.com/RajNykDhulapkar/gotiny/internals/domains"
 	"github.com/RajNykDhulapkar/gotiny/internals/handler"
 	"github.com/RajNykDhulapkar/gotiny/internals/rangeallocator"
+	"github.com/RajNykDhulapkar/gotiny/internals/rangeallocator/embedded"
+	"github.com/RajNykDhulapkar/gotiny/internals/rangeallocator/fake"
+	"github.com/RajNykDhulapkar/gotiny/internals/regions"
 	"github.com/RajNykDhulapkar/gotiny/internals/shortener"
+	"github.com/RajNykDhulapkar/gotiny/internals/urlvalidator"
+	"github.com/RajNykDhulapkar/gotiny/pkg/interfaces"
 	"github.com/gin-gonic/gin"
 )
 
@@ -18,18 +33,66 @@ func main() {
 	clientConfig := &rangeallocator.ClientConfig{
 		Address:     os.Getenv("RANGE_ALLOCATOR_ADDRESS"),
 		DialTimeout: 5 * time.Second,
+		Insecure:    os.Getenv("RANGE_ALLOCATOR_INSECURE") == "true",
+		TLS: &rangeallocator.TLSConfig{
+			CAFile:     os.Getenv("RANGE_ALLOCATOR_TLS_CA_FILE"),
+			CertFile:   os.Getenv("RANGE_ALLOCATOR_TLS_CERT_FILE"),
+			KeyFile:    os.Getenv("RANGE_ALLOCATOR_TLS_KEY_FILE"),
+			ServerName: os.Getenv("RANGE_ALLOCATOR_TLS_SERVER_NAME"),
+		},
+	}
+	if v := os.Getenv("RANGE_ALLOCATOR_TLS_RELOAD_INTERVAL"); v != "" {
+		interval, err := time.ParseDuration(v)
+		if err != nil || interval < 0 {
+			log.Fatalf("Invalid RANGE_ALLOCATOR_TLS_RELOAD_INTERVAL: %q", v)
+		}
+		clientConfig.TLS.ReloadInterval = interval
 	}
 
-	client, err := rangeallocator.NewClient(clientConfig)
+	var (
+		client rangeallocator.Client
+		err    error
+	)
+	allocatorMode := cmp.Or(os.Getenv("GOTINY_RANGE_ALLOCATOR"), "grpc")
+	switch allocatorMode {
+	case "grpc":
+		client, err = rangeallocator.NewClient(clientConfig)
+	case "mongo":
+		embeddedConfig := embedded.DefaultConfig()
+		embeddedConfig.URI = os.Getenv("MONGODB_URI")
+		embeddedConfig.Database = os.Getenv("MONGODB_DATABASE")
+		if v := os.Getenv("GOTINY_EMBEDDED_FIRST_ID"); v != "" {
+			if embeddedConfig.FirstID, err = strconv.ParseInt(v, 10, 64); err != nil || embeddedConfig.FirstID < 0 {
+				log.Fatalf("Invalid GOTINY_EMBEDDED_FIRST_ID: %q", v)
+			}
+		}
+		client, err = embedded.NewMongoAllocator(embeddedConfig)
+	case "fake":
+		log.Printf("Using the in-memory fake range allocator; IDs restart from 1 on every start")
+		client, err = fake.Dial(fake.NewServer(fake.DefaultConfig()))
+	default:
+		log.Fatalf("Invalid GOTINY_RANGE_ALLOCATOR: %q", allocatorMode)
+	}
 	if err != nil {
 		log.Fatalf("Failed to create client: %v", err)
 	}
 	defer client.Close()
 
+	resilientConfig := rangeallocator.DefaultResilientConfig()
+	if v := os.Getenv("GOTINY_RANGE_ALLOCATOR_TIMEOUT"); v != "" {
+		timeout, err := time.ParseDuration(v)
+		if err != nil || timeout <= 0 {
+			log.Fatalf("Invalid GOTINY_RANGE_ALLOCATOR_TIMEOUT: %q", v)
+		}
+		resilientConfig.CallTimeout = timeout
+	}
+	allocator := rangeallocator.NewResilientClient(client, resilientConfig)
+
 	mongodbConfig := &data.Config{
-		URI:        os.Getenv("MONGODB_URI"),
-		Database:   os.Getenv("MONGODB_DATABASE"),
-		Collection: "urls",
+		URI:              os.Getenv("MONGODB_URI"),
+		Database:         os.Getenv("MONGODB_DATABASE"),
+		Collection:       "urls",
+		ExpiredRetention: 7 * 24 * time.Hour,
 	}
 
 	repository, err := data.NewMongoRepository(mongodbConfig)
@@ -38,13 +101,74 @@ func main() {
 	}
 	defer repository.Close(context.Background())
 
+	settings, err := data.NewMongoSettings(&data.Config{
+		URI:        os.Getenv("MONGODB_URI"),
+		Database:   os.Getenv("MONGODB_DATABASE"),
+		Collection: "settings",
+	})
+	if err != nil {
+		log.Fatalf("Failed to create settings store: %v", err)
+	}
+	defer settings.Close(context.Background())
+
+	region := cmp.Or(os.Getenv("GOTINY_REGION"), regions.DefaultRegion)
+
 	managerConfig := &rangeallocator.RangeManagerConfig{
-		ServiceID: os.Getenv("SERVICE_ID"),
-		RangeSize: 1000,
-		Region:    "default",
+		ServiceID:         os.Getenv("SERVICE_ID"),
+		RangeSize:         1000,
+		Region:            region,
+		PrefetchThreshold: 0.2,
+	}
+	if v := os.Getenv("GOTINY_RANGE_PREFETCH_THRESHOLD"); v != "" {
+		threshold, err := strconv.ParseFloat(v, 64)
+		if err != nil || threshold < 0 || threshold >= 1 {
+			log.Fatalf("Invalid GOTINY_RANGE_PREFETCH_THRESHOLD: %q", v)
+		}
+		managerConfig.PrefetchThreshold = threshold
+	}
+	if v := os.Getenv("GOTINY_RANGE_TARGET_REFILL"); v != "" {
+		interval, err := time.ParseDuration(v)
+		if err != nil || interval < 0 {
+			log.Fatalf("Invalid GOTINY_RANGE_TARGET_REFILL: %q", v)
+		}
+		managerConfig.TargetRefillInterval = interval
+		managerConfig.MinRangeSize = 100
+		managerConfig.MaxRangeSize = 10000
+	}
+	if v := os.Getenv("GOTINY_RANGE_MIN_SIZE"); v != "" {
+		if managerConfig.MinRangeSize, err = strconv.ParseInt(v, 10, 64); err != nil {
+			log.Fatalf("Invalid GOTINY_RANGE_MIN_SIZE: %q", v)
+		}
+	}
+	if v := os.Getenv("GOTINY_RANGE_MAX_SIZE"); v != "" {
+		if managerConfig.MaxRangeSize, err = strconv.ParseInt(v, 10, 64); err != nil {
+			log.Fatalf("Invalid GOTINY_RANGE_MAX_SIZE: %q", v)
+		}
+	}
+	if err := managerConfig.Validate(); err != nil {
+		log.Fatalf("Invalid range configuration: %v", err)
+	}
+
+	if v := os.Getenv("GOTINY_NODE_ID"); v != "" {
+		nodeID, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			log.Fatalf("Invalid GOTINY_NODE_ID: %q", v)
+		}
+		if managerConfig.Fallback, err = rangeallocator.NewFallbackGenerator(nodeID, rangeallocator.DefaultFallbackEpoch); err != nil {
+			log.Fatalf("Failed to create fallback ID generator: %v", err)
+		}
+	}
+	if v := os.Getenv("GOTINY_RANGE_CHECKPOINT_FILE"); v != "" {
+		managerConfig.CheckpointPath = v
+		managerConfig.CheckpointMaxAge = time.Hour
 	}
 
-	manager := rangeallocator.NewRangeManager(client, managerConfig)
+	manager := rangeallocator.NewRangeManager(allocator, managerConfig)
+	restoreCtx, cancelRestore := context.WithTimeout(context.Background(), 5*time.Second)
+	if err := manager.Restore(restoreCtx); err != nil {
+		log.Printf("Not resuming checkpointed range: %v", err)
+	}
+	cancelRestore()
 
 	cacheConfig := &cache.Config{
 		Address:      os.Getenv("REDIS_URL"),
@@ -67,21 +191,263 @@ func main() {
 		ServiceID: os.Getenv("SERVICE_ID"),
 		RangeSize: 1000,
 	}
-	shortenerService := shortener.NewShortener(manager, shortenerConfig)
+	if v := os.Getenv("GOTINY_CHECKSUM_MIN_ID"); v != "" {
+		minID, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			log.Fatalf("Invalid GOTINY_CHECKSUM_MIN_ID: %q", v)
+		}
+		shortenerConfig.ChecksumMinID = minID
+	} else if region != regions.DefaultRegion {
+		// Region prefixes only stay clear of the default region's older codes
+		// with the default region's checksum min ID; a new region's own
+		// database would yield 0.
+		log.Fatalf("Invalid GOTINY_REGION: %q needs GOTINY_CHECKSUM_MIN_ID set to the default region's value", region)
+	} else {
+		settingsCtx, cancelSettings := context.WithTimeout(context.Background(), 10*time.Second)
+		shortenerConfig.ChecksumMinID, err = checksumMinID(settingsCtx, settings, repository, manager)
+		cancelSettings()
+		if err != nil {
+			log.Fatalf("Failed to determine the checksum min ID, set GOTINY_CHECKSUM_MIN_ID: %v", err)
+		}
+	}
+	alphabet := os.Getenv("GOTINY_CODE_ALPHABET")
+	minLength := os.Getenv("GOTINY_CODE_MIN_LENGTH")
+	if alphabet != "" || minLength != "" {
+		length := 0
+		if minLength != "" {
+			if length, err = strconv.Atoi(minLength); err != nil {
+				log.Fatalf("Invalid GOTINY_CODE_MIN_LENGTH: %q", minLength)
+			}
+		}
+
+		encoder, err := shortener.NewEncoder(cmp.Or(alphabet, shortener.NewBase62Encoder().Alphabet()), length)
+		if err != nil {
+			log.Fatalf("Invalid code encoding: %v", err)
+		}
+		shortenerConfig.Encoder = encoder
+	}
+
+	settingsCtx, cancelSettings := context.WithTimeout(context.Background(), 10*time.Second)
+	err = checkCodeAlphabet(settingsCtx, settings, repository, cmp.Or(alphabet, shortener.NewBase62Encoder().Alphabet()))
+	cancelSettings()
+	if err != nil {
+		log.Fatalf("Invalid GOTINY_CODE_ALPHABET: %v", err)
+	}
+
+	codeBlocklist := blocklist.New()
+	if path := os.Getenv("GOTINY_BLOCKLIST_FILE"); path != "" {
+		if codeBlocklist, err = blocklist.LoadFile(path); err != nil {
+			log.Fatalf("Failed to load blocklist: %v", err)
+		}
+	}
+	shortenerConfig.Blocklist = codeBlocklist
+
+	regionPrefixes, err := regions.ParsePrefixes(os.Getenv("GOTINY_REGION_PREFIXES"))
+	if err != nil {
+		log.Fatalf("Invalid GOTINY_REGION_PREFIXES: %v", err)
+	}
+	regionRegistry, err := regions.NewRegistry(region, regionPrefixes, cmp.Or(alphabet, shortener.NewBase62Encoder().Alphabet()))
+	if err != nil {
+		log.Fatalf("Invalid GOTINY_REGION_PREFIXES: %v", err)
+	}
+	// Every allocator may number a region's IDs from the start: the range
+	// allocator service does, and so do the embedded and fake ones unless all
+	// regions share their database. Without a prefix a second region would
+	// reissue codes the first one already handed out.
+	if region != regions.DefaultRegion && regionRegistry.Prefix() == "" {
+		log.Fatalf("Invalid GOTINY_REGION: %q needs a prefix in GOTINY_REGION_PREFIXES", region)
+	}
+	shortenerConfig.RegionPrefix = regionRegistry.Prefix()
+	if err := shortenerConfig.Validate(); err != nil {
+		log.Fatalf("Invalid code configuration: %v", err)
+	}
 
-	h := handler.NewHandler(urlCache, shortenerService, repository)
+	strategies := shortener.NewStrategies()
+	strategies.Register(shortener.StrategySequential, shortener.NewShortener(manager, shortenerConfig))
+
+	if key := os.Getenv("GOTINY_ID_OBFUSCATION_KEY"); key != "" {
+		bits := uint64(40)
+		if v := os.Getenv("GOTINY_ID_OBFUSCATION_BITS"); v != "" {
+			if bits, err = strconv.ParseUint(v, 10, 8); err != nil {
+				log.Fatalf("Invalid GOTINY_ID_OBFUSCATION_BITS: %q", v)
+			}
+		}
+
+		obfuscator, err := shortener.NewObfuscator([]byte(key), uint(bits))
+		if err != nil {
+			log.Fatalf("Failed to create ID obfuscator: %v", err)
+		}
+
+		obfuscatedConfig := *shortenerConfig
+		obfuscatedConfig.Obfuscator = obfuscator
+		strategies.Register(shortener.StrategyObfuscated, shortener.NewShortener(manager, &obfuscatedConfig))
+		_ = strategies.SetDefault(shortener.StrategyObfuscated)
+	}
+
+	candidateConfig := shortener.DefaultCandidateConfig()
+	candidateConfig.Blocklist = codeBlocklist
+	if alphabet != "" {
+		candidateConfig.Alphabet = alphabet
+	}
+
+	randomShortener, err := shortener.NewRandomShortener(repository, candidateConfig)
+	if err != nil {
+		log.Fatalf("Failed to create random shortener: %v", err)
+	}
+	strategies.Register(shortener.StrategyRandom, randomShortener)
+
+	hashShortener, err := shortener.NewHashShortener(repository, candidateConfig)
+	if err != nil {
+		log.Fatalf("Failed to create hash shortener: %v", err)
+	}
+	strategies.Register(shortener.StrategyHash, hashShortener)
+
+	if v := os.Getenv("GOTINY_SHORTENER_STRATEGY"); v != "" {
+		if err := strategies.SetDefault(v); err != nil {
+			log.Fatalf("Invalid GOTINY_SHORTENER_STRATEGY: %v (available: %s)", err, strings.Join(strategies.Names(), ", "))
+		}
+	}
+
+	handlerConfig := handler.DefaultConfig()
+	if v := os.Getenv("GOTINY_REDIRECT_STATUS"); v != "" {
+		status, err := strconv.Atoi(v)
+		if err != nil || !handler.IsValidRedirectStatus(status) {
+			log.Fatalf("Invalid GOTINY_REDIRECT_STATUS: %q", v)
+		}
+		handlerConfig.DefaultRedirectStatus = status
+	}
+
+	baseURL := os.Getenv("GOTINY_BASE_URL")
+	if baseURL == "" {
+		baseURL = handlerConfig.Domains.Default().BaseURL
+	}
+	var extraDomains []string
+	if v := os.Getenv("GOTINY_DOMAINS"); v != "" {
+		extraDomains = strings.Split(v, ",")
+	}
+	registry, err := domains.NewRegistry(baseURL, extraDomains...)
+	if err != nil {
+		log.Fatalf("Invalid domain configuration: %v", err)
+	}
+	handlerConfig.Domains = registry
+
+	validatorConfig := urlvalidator.DefaultConfig()
+	validatorConfig.BlockedHosts = registry.Names()
+	handlerConfig.URLValidator = urlvalidator.New(validatorConfig)
+
+	handlerConfig.Blocklist = codeBlocklist
+	handlerConfig.Regions = regionRegistry
+
+	h := handler.NewHandler(urlCache, strategies, repository, handlerConfig)
+
+	idempotencyConfig := handler.DefaultIdempotencyConfig()
+	if v := os.Getenv("GOTINY_IDEMPOTENCY_WINDOW"); v != "" {
+		window, err := time.ParseDuration(v)
+		if err != nil || window <= 0 {
+			log.Fatalf("Invalid GOTINY_IDEMPOTENCY_WINDOW: %q", v)
+		}
+		idempotencyConfig.Window = window
+	}
 
 	r := gin.Default()
 
 	r.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{"message": "pong"})
+		c.JSON(200, gin.H{
+			"message":         "pong",
+			"range_manager":   manager.Stats(),
+			"range_allocator": allocator.Stats(),
+		})
 	})
 
-	r.POST("/create-short-url", h.CreateShortURL)
+	r.POST("/create-short-url", handler.Idempotency(redisAdapter, idempotencyConfig), h.CreateShortURL)
 	r.GET("/:shortUrl", h.HandleShortURLRedirect)
 	r.GET("/urls/:userId", h.GetURLsByUserID) // Add this new route
+	r.GET("/api/resolve/:shortUrl", h.ResolveShortURL)
+	r.PATCH("/api/urls/:shortUrl", h.UpdateShortURL)
+	r.POST("/api/urls/:shortUrl/disable", h.DisableShortURL)
+	r.POST("/api/urls/:shortUrl/enable", h.EnableShortURL)
+	r.DELETE("/api/urls/:shortUrl", h.DeleteShortURL)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	srv := &http.Server{Addr: ":8080", Handler: r}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Failed to start the web server: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Failed to shut down the web server: %v", err)
+	}
+	// Only after in-flight requests finished, so no ID is handed out after
+	// its range was released or checkpointed.
+	if err := manager.Close(shutdownCtx); err != nil {
+		log.Printf("Failed to release ID ranges: %v", err)
+	}
+}
+
+const checksumMinIDSetting = "checksum_min_id"
+
+// checksumMinID returns the first ID whose code check character is verified.
+// Links saved before check characters were derived from the ID have lower
+// IDs, so on the first start with links already saved it is set to the next
+// ID the allocator hands out. It is stored and stays fixed from then on.
+func checksumMinID(ctx context.Context, settings *data.MongoSettings, repository interfaces.URLRepository, manager *rangeallocator.RangeManager) (int64, error) {
+	var minID int64
+	if ok, err := settings.Load(ctx, checksumMinIDSetting, &minID); err != nil || ok {
+		return minID, err
+	}
+
+	hasURLs, err := repository.HasURLs(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	if hasURLs {
+		if minID, err = manager.GetNextID(ctx); err != nil {
+			return 0, fmt.Errorf("failed to get next ID: %w", err)
+		}
+		if rangeallocator.IsFallbackID(minID) {
+			return 0, errors.New("the range allocator is unavailable")
+		}
+	}
+	err = settings.LoadOrStore(ctx, checksumMinIDSetting, minID, &minID)
+	return minID, err
+}
+
+const codeAlphabetSetting = "code_alphabet"
+
+// checkCodeAlphabet records the alphabet codes are generated over and refuses
+// to switch to another one once links exist: check characters only match
+// over the alphabet a code was issued with, so every existing generated code
+// would be rejected. Links saved before the alphabet was recorded used the
+// default one.
+func checkCodeAlphabet(ctx context.Context, settings *data.MongoSettings, repository interfaces.URLRepository, alphabet string) error {
+	stored := shortener.NewBase62Encoder().Alphabet()
+	recorded, err := settings.Load(ctx, codeAlphabetSetting, &stored)
+	if err != nil {
+		return err
+	}
+	if recorded && stored == alphabet {
+		return nil
+	}
 
-	if err := r.Run(":8080"); err != nil {
-		log.Fatalf("Failed to start the web server: %v", err)
+	if stored != alphabet {
+		hasURLs, err := repository.HasURLs(ctx)
+		if err != nil {
+			return err
+		}
+		if hasURLs {
+			return fmt.Errorf("existing links were generated over %q, which cannot change once links exist", stored)
+		}
 	}
+	return settings.Store(ctx, codeAlphabetSetting, alphabet)
 }
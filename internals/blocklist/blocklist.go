@@ -0,0 +1,164 @@
+package blocklist
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// reservedWords collide with our own routes and must never be used as codes.
+var reservedWords = []string{
+	"admin", "api", "create-short-url", "favicon.ico", "health", "login",
+	"logout", "metrics", "robots.txt", "static", "urls",
+}
+
+// offensiveWords are rejected anywhere inside a code, including leet-speak
+// spellings such as "sh1t".
+var offensiveWords = []string{
+	"anal", "arse", "bastard", "bitch", "boob", "cock", "cunt", "dick", "dildo",
+	"fag", "fuck", "jizz", "kike", "nazi", "nigg", "penis", "piss", "porn",
+	"pussy", "rape", "shit", "slut", "spic", "tits", "twat", "vagina", "wank",
+	"whore",
+}
+
+// inflections are the endings an alias word may add to an offensive word and
+// still be blocked, e.g. "fuckers". Longer endings make a different word, as
+// in "cocktail" or "analytics".
+var inflections = []string{"", "s", "es", "er", "ers", "ed", "ing", "a"}
+
+var leetReplacer = strings.NewReplacer(
+	"0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "8", "b", "9", "g",
+	"-", "", "_", "",
+)
+
+// Blocklist decides which codes may not be handed out. A nil Blocklist
+// blocks nothing.
+type Blocklist struct {
+	reserved  map[string]struct{}
+	offensive []string
+}
+
+// New returns a blocklist with the built-in reserved and offensive words plus
+// extraWords, which are matched like offensive words.
+func New(extraWords ...string) *Blocklist {
+	b := &Blocklist{
+		reserved:  make(map[string]struct{}, len(reservedWords)),
+		offensive: append([]string(nil), offensiveWords...),
+	}
+
+	for _, word := range reservedWords {
+		b.reserved[word] = struct{}{}
+	}
+	for _, word := range extraWords {
+		if word = leetReplacer.Replace(strings.ToLower(strings.TrimSpace(word))); word != "" {
+			b.offensive = append(b.offensive, word)
+		}
+	}
+	return b
+}
+
+// LoadFile returns a blocklist extended with the words in path, one per line.
+// Blank lines and lines starting with '#' are ignored.
+func LoadFile(path string) (*Blocklist, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open blocklist: %w", err)
+	}
+	defer file.Close()
+
+	var words []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		words = append(words, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read blocklist: %w", err)
+	}
+
+	return New(words...), nil
+}
+
+// IsBlocked reports whether code is a reserved word or contains an offensive
+// word, ignoring case, separators and common leet-speak substitutions. It is
+// meant for generated codes, which are random enough that rejecting any
+// match costs nothing.
+func (b *Blocklist) IsBlocked(code string) bool {
+	if b == nil {
+		return false
+	}
+
+	lower := strings.ToLower(code)
+	if _, ok := b.reserved[lower]; ok {
+		return true
+	}
+
+	for _, variant := range leetVariants(lower) {
+		for _, word := range b.offensive {
+			if strings.Contains(variant, word) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsBlockedAlias reports whether alias is a reserved word or one of its words
+// is an offensive word, possibly inflected. Words are separated by '-', '_'
+// and lower-to-upper case changes, so aliases such as "grape-sale" or
+// "cocktailWeek" that merely contain an offensive word are allowed.
+func (b *Blocklist) IsBlockedAlias(alias string) bool {
+	if b == nil {
+		return false
+	}
+
+	if _, ok := b.reserved[strings.ToLower(alias)]; ok {
+		return true
+	}
+
+	for _, word := range splitWords(alias) {
+		for _, variant := range leetVariants(strings.ToLower(word)) {
+			for _, offensive := range b.offensive {
+				suffix, ok := strings.CutPrefix(variant, offensive)
+				if ok && slices.Contains(inflections, suffix) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// leetVariants undoes leet-speak in lower. '1' stands for both 'i' and 'l', so
+// there are two readings.
+func leetVariants(lower string) []string {
+	return []string{
+		leetReplacer.Replace(lower),
+		leetReplacer.Replace(strings.ReplaceAll(lower, "1", "l")),
+	}
+}
+
+func splitWords(alias string) []string {
+	var words []string
+	start := 0
+	for i := 0; i < len(alias); i++ {
+		switch c := alias[i]; {
+		case c == '-' || c == '_':
+			words = append(words, alias[start:i])
+			start = i + 1
+		case i > start && isUpper(c) && isLower(alias[i-1]):
+			words = append(words, alias[start:i])
+			start = i
+		}
+	}
+	return append(words, alias[start:])
+}
+
+func isUpper(c byte) bool { return 'A' <= c && c <= 'Z' }
+
+func isLower(c byte) bool { return 'a' <= c && c <= 'z' }
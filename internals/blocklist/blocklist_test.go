@@ -0,0 +1,60 @@
+package blocklist
+
+import "testing"
+
+func TestIsBlocked(t *testing.T) {
+	b := New("acme")
+
+	tests := []struct {
+		code string
+		want bool
+	}{
+		{"health", true},
+		{"xSh1tx", true},
+		{"ab5hitc", true},
+		{"xxAcMexx", true},
+		{"grape", true},
+		{"4bC9x", false},
+	}
+
+	for _, tt := range tests {
+		if got := b.IsBlocked(tt.code); got != tt.want {
+			t.Errorf("IsBlocked(%q) = %v, want %v", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestIsBlockedAlias(t *testing.T) {
+	b := New("acme")
+
+	tests := []struct {
+		alias string
+		want  bool
+	}{
+		{"create-short-url", true},
+		{"sh1t-happens", true},
+		{"big-fuckers", true},
+		{"summer_PORN", true},
+		{"acme-deals", true},
+		{"buyPornNow-x", true},
+		{"grape-sale", false},
+		{"cocktail-week", false},
+		{"analytics-day", false},
+		{"cocktailWeek-x", false},
+		{"scunthorpe-united", false},
+		{"acmeish-deals", false},
+	}
+
+	for _, tt := range tests {
+		if got := b.IsBlockedAlias(tt.alias); got != tt.want {
+			t.Errorf("IsBlockedAlias(%q) = %v, want %v", tt.alias, got, tt.want)
+		}
+	}
+}
+
+func TestNilBlocklist(t *testing.T) {
+	var b *Blocklist
+	if b.IsBlocked("shit") || b.IsBlockedAlias("shit-x") {
+		t.Fatal("a nil blocklist blocked a code")
+	}
+}
@@ -67,10 +67,18 @@ func (r *redisAdapter) Set(ctx context.Context, key string, value interface{}, e
 	return nil
 }
 
+func (r *redisAdapter) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	ok, err := r.client.SetNX(ctx, key, value, expiration).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to set value in Redis: %w", err)
+	}
+	return ok, nil
+}
+
 func (r *redisAdapter) Get(ctx context.Context, key string) (string, error) {
 	value, err := r.client.Get(ctx, key).Result()
 	if err == redis.Nil {
-		return "", fmt.Errorf("key not found: %s", key)
+		return "", fmt.Errorf("%w: %s", ErrKeyNotFound, key)
 	}
 	if err != nil {
 		return "", fmt.Errorf("failed to get value from Redis: %w", err)
@@ -2,6 +2,7 @@ package cache
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"time"
 
@@ -23,10 +24,36 @@ func NewUrlCache(cache interfaces.CachePort) interfaces.UrlCache {
 	}
 }
 
-func (u *urlCacheImpl) SaveUrlMapping(ctx context.Context, shortUrl, originalUrl string, duration time.Duration) error {
-	return u.cache.Set(ctx, shortUrl, originalUrl, duration)
+// urlMappingKey namespaces cache keys per domain. Default domain links keep
+// the bare short URL as key.
+func urlMappingKey(domain, shortUrl string) string {
+	if domain == "" {
+		return shortUrl
+	}
+	return domain + ":" + shortUrl
+}
+
+func (u *urlCacheImpl) SaveUrlMapping(ctx context.Context, domain, shortUrl string, url *interfaces.URLEntity, duration time.Duration) error {
+	value, err := json.Marshal(url)
+	if err != nil {
+		return fmt.Errorf("failed to encode url mapping: %w", err)
+	}
+	return u.cache.Set(ctx, urlMappingKey(domain, shortUrl), value, duration)
+}
+
+func (u *urlCacheImpl) GetUrlMapping(ctx context.Context, domain, shortUrl string) (*interfaces.URLEntity, error) {
+	value, err := u.cache.Get(ctx, urlMappingKey(domain, shortUrl))
+	if err != nil {
+		return nil, err
+	}
+
+	var url interfaces.URLEntity
+	if err := json.Unmarshal([]byte(value), &url); err != nil {
+		return nil, fmt.Errorf("failed to decode url mapping: %w", err)
+	}
+	return &url, nil
 }
 
-func (u *urlCacheImpl) GetOriginalUrl(ctx context.Context, shortUrl string) (string, error) {
-	return u.cache.Get(ctx, shortUrl)
+func (u *urlCacheImpl) DeleteUrlMapping(ctx context.Context, domain, shortUrl string) error {
+	return u.cache.Delete(ctx, urlMappingKey(domain, shortUrl))
 }
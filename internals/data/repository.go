@@ -2,7 +2,9 @@ package data
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/RajNykDhulapkar/gotiny/pkg/interfaces"
@@ -15,8 +17,21 @@ type Config struct {
 	URI        string
 	Database   string
 	Collection string
+	// ExpiredRetention is how long expired and used-up links are kept before
+	// MongoDB's TTL monitor removes them, so they keep answering 410 Gone for
+	// a while. Changing it updates the existing TTL indexes.
+	ExpiredRetention time.Duration
 }
 
+const (
+	// legacyShortURLIndexName is the old unique index on short_url alone,
+	// which predates per-domain code namespaces.
+	legacyShortURLIndexName = "short_url_1"
+	dedupIndexName          = "user_id_domain_original_url_dedup"
+	expiresAtIndexName      = "expires_at_1"
+	exhaustedAtIndexName    = "exhausted_at_1"
+)
+
 type mongoRepository struct {
 	client     *mongo.Client
 	collection *mongo.Collection
@@ -36,23 +51,81 @@ func NewMongoRepository(cfg *Config) (interfaces.URLRepository, error) {
 	}
 
 	collection := client.Database(cfg.Database).Collection(cfg.Collection)
-	return &mongoRepository{
+	repository := &mongoRepository{
 		client:     client,
 		collection: collection,
-	}, nil
+	}
+
+	if err := repository.ensureIndexes(ctx, cfg.ExpiredRetention); err != nil {
+		return nil, err
+	}
+
+	return repository, nil
+}
+
+func (r *mongoRepository) ensureIndexes(ctx context.Context, expiredRetention time.Duration) error {
+	if _, err := r.collection.Indexes().DropOne(ctx, legacyShortURLIndexName); err != nil {
+		var cmdErr mongo.CommandError
+		if !errors.As(err, &cmdErr) || (cmdErr.Name != "IndexNotFound" && cmdErr.Name != "NamespaceNotFound") {
+			return fmt.Errorf("failed to drop legacy index: %w", err)
+		}
+	}
+
+	// CreateMany fails on an existing index with different options, so bring
+	// the TTL of existing indexes in line with the configured retention.
+	expireAfter := int32(expiredRetention.Seconds())
+	for _, name := range []string{expiresAtIndexName, exhaustedAtIndexName} {
+		err := r.collection.Database().RunCommand(ctx, bson.D{
+			{Key: "collMod", Value: r.collection.Name()},
+			{Key: "index", Value: bson.M{"name": name, "expireAfterSeconds": expireAfter}},
+		}).Err()
+		var cmdErr mongo.CommandError
+		if err != nil && (!errors.As(err, &cmdErr) || (cmdErr.Name != "IndexNotFound" && cmdErr.Name != "NamespaceNotFound")) {
+			return fmt.Errorf("failed to update TTL index %s: %w", name, err)
+		}
+	}
+
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "domain", Value: 1}, {Key: "short_url", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "domain", Value: 1}, {Key: "original_url", Value: 1}},
+			Options: options.Index().
+				SetName(dedupIndexName).
+				SetUnique(true).
+				SetPartialFilterExpression(bson.M{"dedup": true}),
+		},
+		{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetName(expiresAtIndexName).SetExpireAfterSeconds(expireAfter),
+		},
+		{
+			Keys:    bson.D{{Key: "exhausted_at", Value: 1}},
+			Options: options.Index().SetName(exhaustedAtIndexName).SetExpireAfterSeconds(expireAfter),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create indexes: %w", err)
+	}
+	return nil
 }
 
 func (r *mongoRepository) Save(ctx context.Context, url *interfaces.URLEntity) error {
 	_, err := r.collection.InsertOne(ctx, url)
 	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return duplicateKeyError(err)
+		}
 		return fmt.Errorf("failed to save URL: %w", err)
 	}
 	return nil
 }
 
-func (r *mongoRepository) FindByShortURL(ctx context.Context, shortURL string) (*interfaces.URLEntity, error) {
+func (r *mongoRepository) FindByShortURL(ctx context.Context, domain, shortURL string) (*interfaces.URLEntity, error) {
 	var result interfaces.URLEntity
-	err := r.collection.FindOne(ctx, bson.M{"short_url": shortURL}).Decode(&result)
+	err := r.collection.FindOne(ctx, bson.M{"domain": domainValue(domain), "short_url": shortURL}).Decode(&result)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			return nil, nil
@@ -62,9 +135,9 @@ func (r *mongoRepository) FindByShortURL(ctx context.Context, shortURL string) (
 	return &result, nil
 }
 
-func (r *mongoRepository) FindByOriginalURL(ctx context.Context, originalURL string) (*interfaces.URLEntity, error) {
+func (r *mongoRepository) FindByOriginalURL(ctx context.Context, domain, userID, originalURL string) (*interfaces.URLEntity, error) {
 	var result interfaces.URLEntity
-	err := r.collection.FindOne(ctx, bson.M{"original_url": originalURL}).Decode(&result)
+	err := r.collection.FindOne(ctx, bson.M{"user_id": userID, "domain": domainValue(domain), "original_url": originalURL, "dedup": true}).Decode(&result)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			return nil, nil
@@ -74,10 +147,10 @@ func (r *mongoRepository) FindByOriginalURL(ctx context.Context, originalURL str
 	return &result, nil
 }
 
-func (r *mongoRepository) IncrementClickCount(ctx context.Context, shortURL string) error {
+func (r *mongoRepository) IncrementClickCount(ctx context.Context, domain, shortURL string) error {
 	_, err := r.collection.UpdateOne(
 		ctx,
-		bson.M{"short_url": shortURL},
+		bson.M{"domain": domainValue(domain), "short_url": shortURL},
 		bson.M{"$inc": bson.M{"click_count": 1}},
 	)
 	if err != nil {
@@ -86,6 +159,26 @@ func (r *mongoRepository) IncrementClickCount(ctx context.Context, shortURL stri
 	return nil
 }
 
+// IncrementClickCountBelow also sets exhausted_at on the last allowed click,
+// so the TTL index purges used-up links.
+func (r *mongoRepository) IncrementClickCountBelow(ctx context.Context, domain, shortURL string, maxClicks int64) (bool, error) {
+	clicks := bson.M{"$add": bson.A{"$click_count", 1}}
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"domain": domainValue(domain), "short_url": shortURL, "click_count": bson.M{"$lt": maxClicks}},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{
+			"click_count": clicks,
+			"exhausted_at": bson.M{"$cond": bson.A{
+				bson.M{"$gte": bson.A{clicks, maxClicks}}, "$$NOW", "$exhausted_at",
+			}},
+		}}}},
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to increment click count: %w", err)
+	}
+	return result.ModifiedCount == 1, nil
+}
+
 func (r *mongoRepository) FindByUserID(ctx context.Context, userID string) ([]*interfaces.URLEntity, error) {
 	cursor, err := r.collection.Find(ctx, bson.M{"user_id": userID})
 	if err != nil {
@@ -100,6 +193,114 @@ func (r *mongoRepository) FindByUserID(ctx context.Context, userID string) ([]*i
 	return results, nil
 }
 
+func (r *mongoRepository) HasURLs(ctx context.Context) (bool, error) {
+	err := r.collection.FindOne(ctx, bson.M{}, options.FindOne().SetProjection(bson.M{"_id": 1})).Err()
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to look for URLs: %w", err)
+	}
+	return true, nil
+}
+
+func (r *mongoRepository) Update(ctx context.Context, domain, shortURL, userID string, update *interfaces.URLUpdate) (*interfaces.URLEntity, error) {
+	set := bson.M{"updated_at": time.Now()}
+	if update.OriginalURL != nil {
+		set["original_url"] = *update.OriginalURL
+	}
+	if update.RedirectStatus != nil {
+		set["redirect_status"] = *update.RedirectStatus
+	}
+	if update.NotBefore != nil {
+		set["not_before"] = *update.NotBefore
+	}
+	if update.ExpiresAt != nil {
+		set["expires_at"] = *update.ExpiresAt
+	}
+	if update.MaxClicks != nil {
+		set["max_clicks"] = *update.MaxClicks
+	}
+	if update.Disabled != nil {
+		set["disabled"] = *update.Disabled
+	}
+
+	changes := bson.M{"$set": set}
+	unset := bson.M{}
+	if update.ClearNotBefore {
+		unset["not_before"] = ""
+	}
+	if update.ClearExpiresAt {
+		unset["expires_at"] = ""
+	}
+	if update.ClearDedup {
+		unset["dedup"] = ""
+	}
+	if update.MaxClicks != nil {
+		// A new limit revives a used-up link; it is marked again below if the
+		// new limit is already reached.
+		unset["exhausted_at"] = ""
+	}
+	if len(unset) > 0 {
+		changes["$unset"] = unset
+	}
+
+	var result interfaces.URLEntity
+	err := r.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"domain": domainValue(domain), "short_url": shortURL, "user_id": userID},
+		changes,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&result)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, duplicateKeyError(err)
+		}
+		return nil, fmt.Errorf("failed to update URL: %w", err)
+	}
+	if update.MaxClicks != nil && result.MaxClicks > 0 && result.ClickCount >= result.MaxClicks {
+		now := time.Now()
+		if _, err := r.collection.UpdateOne(
+			ctx,
+			bson.M{"domain": domainValue(domain), "short_url": shortURL},
+			bson.M{"$set": bson.M{"exhausted_at": now}},
+		); err != nil {
+			return nil, fmt.Errorf("failed to mark URL as used up: %w", err)
+		}
+		result.ExhaustedAt = &now
+	}
+	return &result, nil
+}
+
+func (r *mongoRepository) Delete(ctx context.Context, domain, shortURL, userID string) (bool, error) {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"domain": domainValue(domain), "short_url": shortURL, "user_id": userID})
+	if err != nil {
+		return false, fmt.Errorf("failed to delete URL: %w", err)
+	}
+	return result.DeletedCount == 1, nil
+}
+
+// domainValue matches the stored domain field. Links on the default domain
+// have no domain field, which a nil filter value matches.
+func domainValue(domain string) interface{} {
+	if domain == "" {
+		return nil
+	}
+	return domain
+}
+
+// duplicateKeyError maps a MongoDB duplicate key error to the repository
+// error for the unique index that was violated.
+func duplicateKeyError(err error) error {
+	if strings.Contains(err.Error(), dedupIndexName) {
+		return interfaces.ErrDuplicateURL
+	}
+	return interfaces.ErrShortURLExists
+}
+
 func (r *mongoRepository) Close(ctx context.Context) error {
 	return r.client.Disconnect(ctx)
 }
@@ -0,0 +1,98 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type settingDocument struct {
+	ID    string        `bson:"_id"`
+	Value bson.RawValue `bson:"value"`
+}
+
+// MongoSettings stores deployment-wide values that must stay the same across
+// restarts and replicas once chosen.
+type MongoSettings struct {
+	client     *mongo.Client
+	collection *mongo.Collection
+}
+
+// NewMongoSettings connects to the settings collection named by
+// cfg.Collection.
+func NewMongoSettings(cfg *Config) (*MongoSettings, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(cfg.URI))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to MongoDB: %w", err)
+	}
+
+	if err := client.Ping(ctx, nil); err != nil {
+		return nil, fmt.Errorf("failed to ping MongoDB: %w", err)
+	}
+
+	return &MongoSettings{
+		client:     client,
+		collection: client.Database(cfg.Database).Collection(cfg.Collection),
+	}, nil
+}
+
+// LoadOrStore decodes the value stored under name into result, storing value
+// first if there is none yet. Concurrent callers all get the first stored
+// value.
+func (s *MongoSettings) LoadOrStore(ctx context.Context, name string, value, result interface{}) error {
+	var doc settingDocument
+	err := s.collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": name},
+		bson.M{"$setOnInsert": bson.M{"value": value}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&doc)
+	if err != nil {
+		return fmt.Errorf("failed to load setting %s: %w", name, err)
+	}
+	return decodeSetting(name, doc.Value, result)
+}
+
+// Load decodes the value stored under name into result and reports whether
+// there is one.
+func (s *MongoSettings) Load(ctx context.Context, name string, result interface{}) (bool, error) {
+	var doc settingDocument
+	err := s.collection.FindOne(ctx, bson.M{"_id": name}).Decode(&doc)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to load setting %s: %w", name, err)
+	}
+	return true, decodeSetting(name, doc.Value, result)
+}
+
+// Store sets the value stored under name.
+func (s *MongoSettings) Store(ctx context.Context, name string, value interface{}) error {
+	_, err := s.collection.UpdateOne(ctx,
+		bson.M{"_id": name},
+		bson.M{"$set": bson.M{"value": value}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to store setting %s: %w", name, err)
+	}
+	return nil
+}
+
+func decodeSetting(name string, value bson.RawValue, result interface{}) error {
+	if err := value.Unmarshal(result); err != nil {
+		return fmt.Errorf("failed to decode setting %s: %w", name, err)
+	}
+	return nil
+}
+
+func (s *MongoSettings) Close(ctx context.Context) error {
+	return s.client.Disconnect(ctx)
+}
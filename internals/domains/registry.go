@@ -0,0 +1,119 @@
+package domains
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+var ErrUnknownDomain = errors.New("unknown domain")
+
+// Domain is a public short domain. Each domain has its own code namespace.
+type Domain struct {
+	// Name is the host links are served on, e.g. "gotiny.fun".
+	Name string
+	// BaseURL is the public URL codes are appended to, including scheme and
+	// an optional path prefix.
+	BaseURL string
+	// Key is stored on URLEntity.Domain and used to namespace cache keys. It
+	// is empty for the default domain so links created before domains existed
+	// keep belonging to it.
+	Key string
+}
+
+// ShortLink returns the fully qualified link for code on this domain.
+func (d *Domain) ShortLink(code string) string {
+	return d.BaseURL + "/" + code
+}
+
+type Registry struct {
+	defaultDomain *Domain
+	domains       map[string]*Domain
+}
+
+// NewRegistry builds a registry from public base URLs. The first one is the
+// default domain, used for requests whose host is not registered.
+func NewRegistry(defaultBaseURL string, baseURLs ...string) (*Registry, error) {
+	r := &Registry{domains: make(map[string]*Domain)}
+
+	for i, raw := range append([]string{defaultBaseURL}, baseURLs...) {
+		baseURL, err := ParseBaseURL(raw)
+		if err != nil {
+			return nil, err
+		}
+
+		u, _ := url.Parse(baseURL)
+		name := strings.ToLower(u.Hostname())
+		if _, exists := r.domains[name]; exists {
+			return nil, fmt.Errorf("duplicate domain: %s", name)
+		}
+
+		d := &Domain{Name: name, BaseURL: baseURL, Key: name}
+		if i == 0 {
+			d.Key = ""
+			r.defaultDomain = d
+		}
+		r.domains[name] = d
+	}
+
+	return r, nil
+}
+
+// ParseBaseURL validates a public base URL and returns it without a trailing
+// slash.
+func ParseBaseURL(raw string) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("invalid base url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("invalid base url: scheme must be http or https")
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("invalid base url: missing host")
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return "", fmt.Errorf("invalid base url: query and fragment are not allowed")
+	}
+	return strings.TrimRight(u.String(), "/"), nil
+}
+
+func (r *Registry) Default() *Domain {
+	return r.defaultDomain
+}
+
+// Lookup returns the registered domain called name. An empty name selects the
+// default domain.
+func (r *Registry) Lookup(name string) (*Domain, error) {
+	if name == "" {
+		return r.defaultDomain, nil
+	}
+	d, ok := r.domains[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownDomain, name)
+	}
+	return d, nil
+}
+
+// ForHost returns the domain serving a request's Host header, falling back to
+// the default domain for unregistered hosts such as internal service names.
+func (r *Registry) ForHost(host string) *Domain {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if d, ok := r.domains[strings.ToLower(host)]; ok {
+		return d
+	}
+	return r.defaultDomain
+}
+
+// Names lists every registered domain name.
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.domains))
+	for name := range r.domains {
+		names = append(names, name)
+	}
+	return names
+}
@@ -2,22 +2,94 @@ package handler
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"time"
 
+	"github.com/RajNykDhulapkar/gotiny/internals/blocklist"
+	"github.com/RajNykDhulapkar/gotiny/internals/domains"
+	"github.com/RajNykDhulapkar/gotiny/internals/regions"
+	"github.com/RajNykDhulapkar/gotiny/internals/shortener"
+	"github.com/RajNykDhulapkar/gotiny/internals/urlvalidator"
 	"github.com/RajNykDhulapkar/gotiny/pkg/interfaces"
 	"github.com/gin-gonic/gin"
 )
 
+const maxGenerateAttempts = 3
+
+// RegionHeader names the region that owns a code this region does not have.
+const RegionHeader = "X-Gotiny-Region"
+
+type Config struct {
+	// DefaultRedirectStatus is used for links that do not set their own.
+	DefaultRedirectStatus int
+	// Domains lists the public short domains links can be created on.
+	Domains *domains.Registry
+	// URLValidator checks and normalizes destination URLs.
+	URLValidator *urlvalidator.Validator
+	// Blocklist rejects aliases that are reserved or offensive.
+	Blocklist *blocklist.Blocklist
+	// Regions tells which region generated a code, for failover routing.
+	Regions *regions.Registry
+}
+
+func DefaultConfig() *Config {
+	registry, _ := domains.NewRegistry("https://gotiny.fun")
+	validatorConfig := urlvalidator.DefaultConfig()
+	validatorConfig.BlockedHosts = registry.Names()
+	regionRegistry, _ := regions.NewRegistry(regions.DefaultRegion, nil, "")
+
+	return &Config{
+		DefaultRedirectStatus: http.StatusFound,
+		Domains:               registry,
+		URLValidator:          urlvalidator.New(validatorConfig),
+		Blocklist:             blocklist.New(),
+		Regions:               regionRegistry,
+	}
+}
+
 type Handler struct {
 	cache      interfaces.UrlCache
-	shortener  interfaces.ShortenerInterface
+	shortener  interfaces.ShortenerStrategies
 	repository interfaces.URLRepository
+	config     *Config
 }
 
 type UrlCreationRequest struct {
-	LongUrl string `json:"long_url" binding:"required"`
-	UserId  string `json:"user_id" binding:"required"`
+	LongUrl        string     `json:"long_url" binding:"required"`
+	UserId         string     `json:"user_id" binding:"required"`
+	RedirectStatus int        `json:"redirect_status"`
+	Alias          string     `json:"alias"`
+	NotBefore      *time.Time `json:"not_before"`
+	ExpiresAt      *time.Time `json:"expires_at"`
+	MaxClicks      int64      `json:"max_clicks"`
+	// Dedup returns the user's existing link for the same URL, if any,
+	// instead of creating a new one. It cannot be combined with options the
+	// existing link may not share.
+	Dedup bool `json:"dedup"`
+	// Domain is the short domain to create the link on; empty selects the
+	// default domain.
+	Domain string `json:"domain"`
+	// Strategy names the short code strategy to use instead of the default.
+	Strategy string `json:"strategy"`
+}
+
+type UrlUpdateRequest struct {
+	UserId         string     `json:"user_id" binding:"required"`
+	LongUrl        *string    `json:"long_url"`
+	RedirectStatus *int       `json:"redirect_status"`
+	NotBefore      *time.Time `json:"not_before"`
+	ExpiresAt      *time.Time `json:"expires_at"`
+	MaxClicks      *int64     `json:"max_clicks"`
+	// ClearNotBefore and ClearExpiresAt remove a previously set limit, since
+	// a missing field leaves it unchanged.
+	ClearNotBefore bool `json:"clear_not_before"`
+	ClearExpiresAt bool `json:"clear_expires_at"`
+}
+
+type UrlOwnerRequest struct {
+	UserId string `json:"user_id" binding:"required"`
 }
 
 type UrlsByUserResponse struct {
@@ -25,12 +97,26 @@ type UrlsByUserResponse struct {
 	Data    []*interfaces.URLEntity `json:"data"`
 }
 
-func NewHandler(cache interfaces.UrlCache, shortener interfaces.ShortenerInterface, repository interfaces.URLRepository) interfaces.HandlerInterface {
+func NewHandler(cache interfaces.UrlCache, shortener interfaces.ShortenerStrategies, repository interfaces.URLRepository, config *Config) interfaces.HandlerInterface {
+	if config == nil {
+		config = DefaultConfig()
+	}
+
 	return &Handler{
 		cache:      cache,
 		shortener:  shortener,
 		repository: repository,
+		config:     config,
+	}
+}
+
+// IsValidRedirectStatus reports whether status can be used to redirect a short link.
+func IsValidRedirectStatus(status int) bool {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
 	}
+	return false
 }
 
 func (h *Handler) CreateShortURL(c *gin.Context) {
@@ -40,79 +126,276 @@ func (h *Handler) CreateShortURL(c *gin.Context) {
 		return
 	}
 
-	shortUrl, err := h.shortener.GenerateShortLink(c, creationRequest.LongUrl, creationRequest.UserId)
+	if creationRequest.RedirectStatus != 0 && !IsValidRedirectStatus(creationRequest.RedirectStatus) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "redirect_status must be one of 301, 302, 307 or 308"})
+		return
+	}
+
+	d, err := h.config.Domains.Lookup(creationRequest.Domain)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	if err := validateLifetime(creationRequest.NotBefore, creationRequest.ExpiresAt, creationRequest.MaxClicks, now); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	longUrl, err := h.config.URLValidator.Normalize(creationRequest.LongUrl)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	if creationRequest.Dedup {
+		if creationRequest.Alias != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "alias and dedup cannot be combined"})
+			return
+		}
+		if creationRequest.RedirectStatus != 0 || creationRequest.NotBefore != nil || creationRequest.ExpiresAt != nil || creationRequest.MaxClicks != 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "dedup cannot be combined with redirect_status, not_before, expires_at or max_clicks"})
+			return
+		}
+
+		existing, err := h.findDedupURL(c, d.Key, creationRequest.UserId, longUrl, now)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to look up URL"})
+			return
+		}
+		if existing != nil {
+			c.JSON(http.StatusOK, h.shortURLResponse("short url already exists", d, existing.ShortURL))
+			return
+		}
+	}
+
+	var generator interfaces.ShortenerInterface
+	if creationRequest.Alias != "" {
+		if err := shortener.ValidateAlias(creationRequest.Alias); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if h.config.Blocklist.IsBlockedAlias(creationRequest.Alias) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "alias is reserved or not allowed"})
+			return
+		}
+	} else {
+		generator, err = h.shortener.Strategy(creationRequest.Strategy)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
 	urlEntity := &interfaces.URLEntity{
-		ShortURL:    shortUrl,
-		OriginalURL: creationRequest.LongUrl,
-		UserID:      creationRequest.UserId,
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
-		ClickCount:  0,
+		Domain:         d.Key,
+		OriginalURL:    longUrl,
+		UserID:         creationRequest.UserId,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		ClickCount:     0,
+		RedirectStatus: creationRequest.RedirectStatus,
+		NotBefore:      creationRequest.NotBefore,
+		ExpiresAt:      creationRequest.ExpiresAt,
+		MaxClicks:      creationRequest.MaxClicks,
+		Dedup:          creationRequest.Dedup,
 	}
 
-	if err := h.repository.Save(c, urlEntity); err != nil {
+	if err := h.saveShortURL(c, urlEntity, creationRequest.Alias, generator); err != nil {
+		if errors.Is(err, interfaces.ErrShortURLExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Alias is already taken"})
+			return
+		}
+		if errors.Is(err, interfaces.ErrDuplicateURL) {
+			// A concurrent request created the same link first.
+			existing, err := h.findDedupURL(c, d.Key, creationRequest.UserId, longUrl, now)
+			if err == nil && existing != nil {
+				c.JSON(http.StatusOK, h.shortURLResponse("short url already exists", d, existing.ShortURL))
+				return
+			}
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save URL"})
 		return
 	}
 
-	if err := h.cache.SaveUrlMapping(c, shortUrl, creationRequest.LongUrl, 6*time.Hour); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+	// The link is already saved, so a cache failure must not turn into a
+	// retryable error that would create a second link.
+	if ttl := cacheTTL(urlEntity, now); ttl > 0 {
+		_ = h.cache.SaveUrlMapping(c, d.Key, urlEntity.ShortURL, urlEntity, ttl)
 	}
 
-	host := "gotiny.fun/"
-	c.JSON(200, gin.H{
-		"message":   "short url created successfully",
-		"short_url": host + shortUrl,
-	})
+	c.JSON(200, h.shortURLResponse("short url created successfully", d, urlEntity.ShortURL))
+}
+
+// findDedupURL returns the user's dedup link for longUrl if it still
+// redirects. A disabled, expired or used-up link is taken out of dedup mode
+// instead, so the caller creates a fresh one in its place.
+func (h *Handler) findDedupURL(c *gin.Context, domain, userID, longUrl string, now time.Time) (*interfaces.URLEntity, error) {
+	existing, err := h.repository.FindByOriginalURL(c, domain, userID, longUrl)
+	if err != nil || existing == nil {
+		return existing, err
+	}
+	if isLive(existing, now) {
+		return existing, nil
+	}
+
+	if _, err := h.repository.Update(c, domain, existing.ShortURL, userID, &interfaces.URLUpdate{ClearDedup: true}); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// saveShortURL saves urlEntity under alias, or under a code from generator
+// when alias is empty. Generated codes can still lose a race against another
+// strategy or replica, so those are regenerated a few times before giving up.
+func (h *Handler) saveShortURL(c *gin.Context, urlEntity *interfaces.URLEntity, alias string, generator interfaces.ShortenerInterface) error {
+	if alias != "" {
+		urlEntity.ShortURL = alias
+		return h.repository.Save(c, urlEntity)
+	}
+
+	var err error
+	for attempt := 0; attempt < maxGenerateAttempts; attempt++ {
+		urlEntity.ShortURL, err = generator.GenerateShortLink(c, urlEntity.Domain, urlEntity.OriginalURL, urlEntity.UserID)
+		if err != nil {
+			return err
+		}
+
+		err = h.repository.Save(c, urlEntity)
+		if !errors.Is(err, interfaces.ErrShortURLExists) {
+			return err
+		}
+	}
+	return fmt.Errorf("failed to generate a free short url after %d attempts", maxGenerateAttempts)
+}
+
+func (h *Handler) shortURLResponse(message string, d *domains.Domain, shortUrl string) gin.H {
+	return gin.H{
+		"message":   message,
+		"code":      shortUrl,
+		"domain":    d.Name,
+		"short_url": d.ShortLink(shortUrl),
+	}
+}
+
+// requestDomain returns the domain named by the "domain" query parameter,
+// defaulting to the default domain. It writes the error response itself and
+// reports false when the domain is unknown.
+func (h *Handler) requestDomain(c *gin.Context) (*domains.Domain, bool) {
+	d, err := h.config.Domains.Lookup(c.Query("domain"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return d, true
 }
 
 func (h *Handler) HandleShortURLRedirect(c *gin.Context) {
-	shortUrl := c.Param("shortUrl")
+	urlEntity, ok := h.lookupShortURL(c, h.config.Domains.ForHost(c.Request.Host))
+	if !ok {
+		return
+	}
 
-	originalUrl, err := h.cache.GetOriginalUrl(c, shortUrl)
+	status := urlEntity.RedirectStatus
+	if status == 0 {
+		status = h.config.DefaultRedirectStatus
+	}
 
-	if err == nil {
-		go func() {
-			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-			defer cancel()
-			_ = h.repository.IncrementClickCount(ctx, shortUrl)
-		}()
+	c.Redirect(status, urlEntity.OriginalURL)
+}
 
-		c.JSON(http.StatusOK, gin.H{
-			"message":      "URL retrieved successfully",
-			"original_url": originalUrl,
-		})
+func (h *Handler) ResolveShortURL(c *gin.Context) {
+	d, ok := h.requestDomain(c)
+	if !ok {
 		return
 	}
 
-	urlEntity, err := h.repository.FindByShortURL(c, shortUrl)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve URL"})
+	urlEntity, ok := h.lookupShortURL(c, d)
+	if !ok {
 		return
 	}
-	if urlEntity == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "URL retrieved successfully",
+		"original_url": urlEntity.OriginalURL,
+	})
+}
+
+// notFound answers 404 for a code missing from this region's datastore. If
+// the code was generated in another region, that region is named so a
+// failover router can retry there.
+func (h *Handler) notFound(c *gin.Context, code string) {
+	if owner, ok := h.config.Regions.OwnerOf(code); ok && owner != h.config.Regions.Local() {
+		c.Header(RegionHeader, owner)
+		c.JSON(http.StatusNotFound, gin.H{"error": "URL not found", "region": owner})
 		return
 	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
+}
+
+// lookupShortURL resolves the shortUrl route parameter on domain d through the
+// cache and repository, enforces the link's lifetime and records a click. It writes the
+// error response itself and reports false when the link cannot be served.
+func (h *Handler) lookupShortURL(c *gin.Context, d *domains.Domain) (*interfaces.URLEntity, bool) {
+	shortUrl := c.Param("shortUrl")
+	if err := h.shortener.ValidateShortLink(shortUrl); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
+		return nil, false
+	}
 
-	_ = h.cache.SaveUrlMapping(c, shortUrl, urlEntity.OriginalURL, 6*time.Hour)
+	urlEntity, err := h.cache.GetUrlMapping(c, d.Key, shortUrl)
+	if err != nil {
+		urlEntity, err = h.repository.FindByShortURL(c, d.Key, shortUrl)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve URL"})
+			return nil, false
+		}
+		if urlEntity == nil {
+			h.notFound(c, shortUrl)
+			return nil, false
+		}
+
+		if ttl := cacheTTL(urlEntity, time.Now()); ttl > 0 {
+			_ = h.cache.SaveUrlMapping(c, d.Key, shortUrl, urlEntity, ttl)
+		}
+	}
+
+	if urlEntity.Disabled {
+		c.JSON(http.StatusGone, gin.H{"error": "URL has been disabled"})
+		return nil, false
+	}
+
+	now := time.Now()
+	if urlEntity.ExpiresAt != nil && !now.Before(*urlEntity.ExpiresAt) {
+		c.JSON(http.StatusGone, gin.H{"error": "URL has expired"})
+		return nil, false
+	}
+	if urlEntity.NotBefore != nil && now.Before(*urlEntity.NotBefore) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "URL is not active yet"})
+		return nil, false
+	}
+
+	if urlEntity.MaxClicks > 0 {
+		counted, err := h.repository.IncrementClickCountBelow(c, d.Key, shortUrl, urlEntity.MaxClicks)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve URL"})
+			return nil, false
+		}
+		if !counted {
+			c.JSON(http.StatusGone, gin.H{"error": "URL has reached its click limit"})
+			return nil, false
+		}
+		return urlEntity, true
+	}
 
 	go func() {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
-		_ = h.repository.IncrementClickCount(ctx, shortUrl)
+		_ = h.repository.IncrementClickCount(ctx, d.Key, shortUrl)
 	}()
 
-	c.JSON(http.StatusOK, gin.H{
-		"message":      "URL retrieved successfully",
-		"original_url": urlEntity.OriginalURL,
-	})
+	return urlEntity, true
 }
 
 func (h *Handler) GetURLsByUserID(c *gin.Context) {
@@ -145,3 +428,132 @@ func (h *Handler) GetURLsByUserID(c *gin.Context) {
 		Data:    urls,
 	})
 }
+
+func (h *Handler) UpdateShortURL(c *gin.Context) {
+	var updateRequest UrlUpdateRequest
+	if err := c.ShouldBindJSON(&updateRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if updateRequest.LongUrl != nil {
+		longUrl, err := h.config.URLValidator.Normalize(*updateRequest.LongUrl)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+		updateRequest.LongUrl = &longUrl
+	}
+
+	if updateRequest.RedirectStatus != nil && *updateRequest.RedirectStatus != 0 && !IsValidRedirectStatus(*updateRequest.RedirectStatus) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "redirect_status must be one of 301, 302, 307 or 308"})
+		return
+	}
+
+	if (updateRequest.ClearNotBefore && updateRequest.NotBefore != nil) || (updateRequest.ClearExpiresAt && updateRequest.ExpiresAt != nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "a limit cannot be both set and cleared"})
+		return
+	}
+
+	var maxClicks int64
+	if updateRequest.MaxClicks != nil {
+		maxClicks = *updateRequest.MaxClicks
+	}
+	if err := validateLifetime(updateRequest.NotBefore, updateRequest.ExpiresAt, maxClicks, time.Now()); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.applyUpdate(c, updateRequest.UserId, &interfaces.URLUpdate{
+		OriginalURL:    updateRequest.LongUrl,
+		RedirectStatus: updateRequest.RedirectStatus,
+		NotBefore:      updateRequest.NotBefore,
+		ExpiresAt:      updateRequest.ExpiresAt,
+		MaxClicks:      updateRequest.MaxClicks,
+		ClearNotBefore: updateRequest.ClearNotBefore,
+		ClearExpiresAt: updateRequest.ClearExpiresAt,
+	}, "URL updated successfully")
+}
+
+func (h *Handler) DisableShortURL(c *gin.Context) {
+	h.setDisabled(c, true, "URL disabled successfully")
+}
+
+func (h *Handler) EnableShortURL(c *gin.Context) {
+	h.setDisabled(c, false, "URL enabled successfully")
+}
+
+func (h *Handler) setDisabled(c *gin.Context, disabled bool, message string) {
+	var ownerRequest UrlOwnerRequest
+	if err := c.ShouldBindJSON(&ownerRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.applyUpdate(c, ownerRequest.UserId, &interfaces.URLUpdate{Disabled: &disabled}, message)
+}
+
+// applyUpdate updates the link named by the shortUrl route parameter and
+// evicts it from the cache so the change is served immediately.
+func (h *Handler) applyUpdate(c *gin.Context, userID string, update *interfaces.URLUpdate, message string) {
+	shortUrl := c.Param("shortUrl")
+	d, ok := h.requestDomain(c)
+	if !ok {
+		return
+	}
+
+	urlEntity, err := h.repository.Update(c, d.Key, shortUrl, userID, update)
+	if err != nil {
+		if errors.Is(err, interfaces.ErrDuplicateURL) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Another link already points to this URL"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update URL"})
+		return
+	}
+	if urlEntity == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
+		return
+	}
+
+	if err := h.cache.DeleteUrlMapping(c, d.Key, shortUrl); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": message,
+		"data":    urlEntity,
+	})
+}
+
+func (h *Handler) DeleteShortURL(c *gin.Context) {
+	shortUrl := c.Param("shortUrl")
+	var ownerRequest UrlOwnerRequest
+	if err := c.ShouldBindJSON(&ownerRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	d, ok := h.requestDomain(c)
+	if !ok {
+		return
+	}
+
+	deleted, err := h.repository.Delete(c, d.Key, shortUrl, ownerRequest.UserId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete URL"})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
+		return
+	}
+
+	if err := h.cache.DeleteUrlMapping(c, d.Key, shortUrl); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "URL deleted successfully"})
+}
@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/RajNykDhulapkar/gotiny/internals/utils"
+	"github.com/RajNykDhulapkar/gotiny/pkg/interfaces"
+	"github.com/gin-gonic/gin"
+)
+
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+type IdempotencyConfig struct {
+	// Window is how long a completed response is replayed for.
+	Window time.Duration
+	// LockTimeout bounds how long an in-flight request holds the key, so a
+	// crashed replica cannot block retries forever.
+	LockTimeout time.Duration
+	// WaitTimeout is how long a concurrent duplicate waits for the in-flight
+	// request before giving up with 409 Conflict.
+	WaitTimeout  time.Duration
+	PollInterval time.Duration
+}
+
+func DefaultIdempotencyConfig() *IdempotencyConfig {
+	return &IdempotencyConfig{
+		Window:       24 * time.Hour,
+		LockTimeout:  30 * time.Second,
+		WaitTimeout:  10 * time.Second,
+		PollInterval: 100 * time.Millisecond,
+	}
+}
+
+type idempotencyRecord struct {
+	Pending     bool   `json:"pending"`
+	RequestHash string `json:"request_hash"`
+	Status      int    `json:"status,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+// recordingWriter keeps a copy of the response body so it can be replayed.
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Idempotency makes the wrapped route safe to retry. The first request with a
+// given Idempotency-Key runs normally and its response is stored in the cache;
+// later requests with the same key replay that response, and requests that
+// arrive while the first one is still running wait for it to finish.
+func Idempotency(cache interfaces.CachePort, config *IdempotencyConfig) gin.HandlerFunc {
+	if config == nil {
+		config = DefaultIdempotencyConfig()
+	}
+
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		cacheKey := "idempotency:" + c.FullPath() + ":" + key
+		requestHash := hex.EncodeToString(utils.Sha256Of(string(body)))
+		pending, _ := json.Marshal(idempotencyRecord{Pending: true, RequestHash: requestHash})
+
+		deadline := time.Now().Add(config.WaitTimeout)
+		for {
+			acquired, err := cache.SetNX(c, cacheKey, pending, config.LockTimeout)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Idempotency store unavailable"})
+				return
+			}
+			if acquired {
+				runIdempotent(c, cache, cacheKey, requestHash, config.Window)
+				return
+			}
+
+			if value, err := cache.Get(c, cacheKey); err == nil {
+				var record idempotencyRecord
+				if err := json.Unmarshal([]byte(value), &record); err == nil && !record.Pending {
+					if record.RequestHash != requestHash {
+						c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was already used with a different request"})
+						return
+					}
+					c.Header("Idempotent-Replayed", "true")
+					c.Data(record.Status, "application/json; charset=utf-8", record.Body)
+					c.Abort()
+					return
+				}
+			}
+
+			if time.Now().After(deadline) {
+				c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "A request with this Idempotency-Key is still in progress"})
+				return
+			}
+
+			select {
+			case <-c.Request.Context().Done():
+				c.Abort()
+				return
+			case <-time.After(config.PollInterval):
+			}
+		}
+	}
+}
+
+func runIdempotent(c *gin.Context, cache interfaces.CachePort, cacheKey, requestHash string, window time.Duration) {
+	writer := &recordingWriter{ResponseWriter: c.Writer}
+	c.Writer = writer
+	c.Next()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Server errors are not final; release the key so the client can retry.
+	if writer.Status() >= http.StatusInternalServerError {
+		_ = cache.Delete(ctx, cacheKey)
+		return
+	}
+
+	record, _ := json.Marshal(idempotencyRecord{
+		RequestHash: requestHash,
+		Status:      writer.Status(),
+		Body:        writer.body.Bytes(),
+	})
+	_ = cache.Set(ctx, cacheKey, record, window)
+}
@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"errors"
+	"time"
+
+	"github.com/RajNykDhulapkar/gotiny/pkg/interfaces"
+)
+
+const defaultCacheTTL = 6 * time.Hour
+
+var (
+	errExpiresInPast     = errors.New("expires_at must be in the future")
+	errNotBeforeAfterEnd = errors.New("not_before must be before expires_at")
+	errNegativeMaxClicks = errors.New("max_clicks must not be negative")
+)
+
+func validateLifetime(notBefore, expiresAt *time.Time, maxClicks int64, now time.Time) error {
+	if expiresAt != nil && !expiresAt.After(now) {
+		return errExpiresInPast
+	}
+	if notBefore != nil && expiresAt != nil && !notBefore.Before(*expiresAt) {
+		return errNotBeforeAfterEnd
+	}
+	if maxClicks < 0 {
+		return errNegativeMaxClicks
+	}
+	return nil
+}
+
+// isLive reports whether url currently redirects.
+func isLive(url *interfaces.URLEntity, now time.Time) bool {
+	switch {
+	case url.Disabled:
+		return false
+	case url.NotBefore != nil && now.Before(*url.NotBefore):
+		return false
+	case url.ExpiresAt != nil && !now.Before(*url.ExpiresAt):
+		return false
+	case url.MaxClicks > 0 && url.ClickCount >= url.MaxClicks:
+		return false
+	}
+	return true
+}
+
+// cacheTTL caps the cache lifetime of a link at its remaining lifetime. A
+// non-positive result means the link must not be cached.
+func cacheTTL(url *interfaces.URLEntity, now time.Time) time.Duration {
+	ttl := defaultCacheTTL
+	if url.ExpiresAt != nil {
+		if remaining := url.ExpiresAt.Sub(now); remaining < ttl {
+			ttl = remaining
+		}
+	}
+	return ttl
+}
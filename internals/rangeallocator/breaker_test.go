@@ -0,0 +1,70 @@
+package rangeallocator
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	const cooldown = time.Minute
+	b := newBreaker(3, cooldown)
+	now := time.Now()
+
+	expect := func(state BreakerState) {
+		t.Helper()
+		if got := b.State(); got != state {
+			t.Fatalf("state = %s, want %s", got, state)
+		}
+	}
+
+	// A success resets the count of consecutive failures.
+	b.failure(now)
+	b.failure(now)
+	b.success()
+	b.failure(now)
+	b.failure(now)
+	expect(BreakerClosed)
+	if !b.allow(now) {
+		t.Fatal("closed breaker rejected a call")
+	}
+
+	b.failure(now)
+	expect(BreakerOpen)
+	if b.allow(now.Add(cooldown - time.Second)) {
+		t.Fatal("open breaker allowed a call before the cooldown")
+	}
+
+	// After the cooldown a single probe goes through.
+	now = now.Add(cooldown)
+	if !b.allow(now) {
+		t.Fatal("breaker rejected the probe after the cooldown")
+	}
+	expect(BreakerHalfOpen)
+	if b.allow(now) {
+		t.Fatal("half-open breaker allowed a second call during the probe")
+	}
+
+	// A failed probe opens the breaker again for a full cooldown.
+	b.failure(now)
+	expect(BreakerOpen)
+	if b.allow(now.Add(cooldown - time.Second)) {
+		t.Fatal("breaker allowed a call right after a failed probe")
+	}
+
+	// An abandoned probe lets the next call probe instead.
+	now = now.Add(cooldown)
+	if !b.allow(now) {
+		t.Fatal("breaker rejected the second probe")
+	}
+	b.abandon()
+	expect(BreakerHalfOpen)
+	if !b.allow(now) {
+		t.Fatal("breaker rejected a probe after the previous one was abandoned")
+	}
+
+	b.success()
+	expect(BreakerClosed)
+	if !b.allow(now) {
+		t.Fatal("breaker rejected a call after a successful probe")
+	}
+}
@@ -0,0 +1,74 @@
+package rangeallocator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// checkpoint is the unused tail of a range, saved on graceful shutdown so the
+// next start of the same replica can keep handing out IDs from it.
+type checkpoint struct {
+	RangeID   string    `json:"range_id"`
+	ServiceID string    `json:"service_id"`
+	Region    string    `json:"region,omitempty"`
+	NextID    int64     `json:"next_id"`
+	EndID     int64     `json:"end_id"`
+	SavedAt   time.Time `json:"saved_at"`
+}
+
+// writeCheckpoint replaces path atomically so a crash mid-write never leaves a
+// truncated checkpoint behind.
+func writeCheckpoint(path string, cp *checkpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoint: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	return nil
+}
+
+// takeCheckpoint reads and deletes the checkpoint at path. Deleting it first
+// matters: if the process crashes after resuming, a stale checkpoint would
+// hand out the same IDs again. It returns nil when there is no checkpoint.
+func takeCheckpoint(path string) (*checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+	if err := os.Remove(path); err != nil {
+		return nil, fmt.Errorf("failed to remove checkpoint: %w", err)
+	}
+
+	var cp checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("failed to decode checkpoint: %w", err)
+	}
+	return &cp, nil
+}
@@ -0,0 +1,138 @@
+package rangeallocator_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/RajNykDhulapkar/gotiny-range-allocator/pkg/pb"
+	"github.com/RajNykDhulapkar/gotiny/internals/rangeallocator"
+	"github.com/RajNykDhulapkar/gotiny/internals/rangeallocator/fake"
+)
+
+// checkpointRange takes one ID from a fresh range and closes the manager,
+// which checkpoints the rest of the range. It returns the range and the ID.
+func checkpointRange(t *testing.T, server *fake.Server, path string) (*pb.Range, int64) {
+	t.Helper()
+
+	manager := server.NewRangeManager(t, &rangeallocator.RangeManagerConfig{
+		ServiceID:      "test",
+		RangeSize:      100,
+		CheckpointPath: path,
+	})
+	id, err := manager.GetNextID(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rng := manager.GetCurrentRange()
+	if err := manager.Close(context.Background()); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("no checkpoint after Close: %v", err)
+	}
+	return rng, id
+}
+
+func rangeStatus(server *fake.Server, rangeID string) pb.RangeStatus {
+	for _, r := range server.Ranges() {
+		if r.RangeId == rangeID {
+			return r.Status
+		}
+	}
+	return pb.RangeStatus_RANGE_STATUS_UNSPECIFIED
+}
+
+func TestRangeManagerRestore(t *testing.T) {
+	tests := []struct {
+		name string
+		// prepare runs between checkpointing and restoring.
+		prepare    func(t *testing.T, server *fake.Server, rangeID string)
+		serviceID  string
+		region     string
+		maxAge     time.Duration
+		wantResume bool
+		wantStatus pb.RangeStatus
+	}{
+		{
+			name:       "resume",
+			serviceID:  "test",
+			maxAge:     time.Hour,
+			wantResume: true,
+			wantStatus: pb.RangeStatus_RANGE_STATUS_ACTIVE,
+		},
+		{
+			name:       "wrong service",
+			serviceID:  "other",
+			wantStatus: pb.RangeStatus_RANGE_STATUS_ACTIVE,
+		},
+		{
+			name:       "wrong region",
+			serviceID:  "test",
+			region:     "eu-west",
+			wantStatus: pb.RangeStatus_RANGE_STATUS_RELEASED,
+		},
+		{
+			name:       "stale",
+			serviceID:  "test",
+			maxAge:     time.Nanosecond,
+			wantStatus: pb.RangeStatus_RANGE_STATUS_RELEASED,
+		},
+		{
+			name: "already released",
+			prepare: func(t *testing.T, server *fake.Server, rangeID string) {
+				_, err := server.UpdateRangeStatus(context.Background(), &pb.UpdateRangeStatusRequest{
+					RangeId:   rangeID,
+					ServiceId: "test",
+					Status:    pb.RangeStatus_RANGE_STATUS_RELEASED,
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+			},
+			serviceID:  "test",
+			wantStatus: pb.RangeStatus_RANGE_STATUS_RELEASED,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := fake.NewServer(fake.DefaultConfig())
+			path := filepath.Join(t.TempDir(), "range.json")
+			saved, lastID := checkpointRange(t, server, path)
+			if tt.prepare != nil {
+				tt.prepare(t, server, saved.RangeId)
+			}
+
+			manager := server.NewRangeManager(t, &rangeallocator.RangeManagerConfig{
+				ServiceID:        tt.serviceID,
+				RangeSize:        100,
+				Region:           tt.region,
+				CheckpointPath:   path,
+				CheckpointMaxAge: tt.maxAge,
+			})
+			if err := manager.Restore(context.Background()); err != nil {
+				t.Fatalf("Restore: %v", err)
+			}
+			if _, err := os.Stat(path); !os.IsNotExist(err) {
+				t.Errorf("checkpoint still exists after Restore: %v", err)
+			}
+
+			id, err := manager.GetNextID(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resumed := id == lastID+1; resumed != tt.wantResume {
+				t.Errorf("got ID %d after %d, want resumed: %v", id, lastID, tt.wantResume)
+			}
+			if !tt.wantResume && lastID <= id && id <= saved.EndId {
+				t.Errorf("ID %d is from the checkpointed range ending at %d", id, saved.EndId)
+			}
+			if status := rangeStatus(server, saved.RangeId); status != tt.wantStatus {
+				t.Errorf("checkpointed range is %s, want %s", status, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/RajNykDhulapkar/gotiny-range-allocator/pkg/pb"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/protobuf/types/known/emptypb"
 )
@@ -14,10 +15,15 @@ import (
 type ClientConfig struct {
 	Address     string        `yaml:"address"`
 	DialTimeout time.Duration `yaml:"dialTimeout"`
+	// Insecure dials without TLS. Otherwise the connection uses TLS, with
+	// mTLS when TLS carries a client certificate.
+	Insecure bool       `yaml:"insecure"`
+	TLS      *TLSConfig `yaml:"tls"`
 }
 
 type Client interface {
 	AllocateRange(ctx context.Context, serviceID string, size *int64, region *string) (*pb.Range, error)
+	GetRange(ctx context.Context, rangeID string) (*pb.Range, error)
 	UpdateRangeStatus(ctx context.Context, rangeID, serviceID string, status pb.RangeStatus) (*pb.Range, error)
 	GetHealth(ctx context.Context) error
 	Close() error
@@ -28,23 +34,44 @@ type clientImpl struct {
 	client pb.RangeAllocatorClient
 }
 
+// NewClient does not wait for the allocator to be reachable, so the service
+// can start while it is down; calls fail until the connection is up.
 func NewClient(cfg *ClientConfig) (*clientImpl, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), cfg.DialTimeout)
-	defer cancel()
+	creds := insecure.NewCredentials()
+	if !cfg.Insecure {
+		tlsConfig := cfg.TLS
+		if tlsConfig == nil {
+			tlsConfig = &TLSConfig{}
+		}
+		tlsCreds, err := newReloadingCredentials(tlsConfig)
+		if err != nil {
+			return nil, err
+		}
+		creds = tlsCreds
+	}
 
-	conn, err := grpc.DialContext(ctx,
+	conn, err := grpc.NewClient(
 		cfg.Address,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-		grpc.WithBlock(),
+		grpc.WithTransportCredentials(creds),
+		grpc.WithConnectParams(grpc.ConnectParams{
+			Backoff:           backoff.DefaultConfig,
+			MinConnectTimeout: cfg.DialTimeout,
+		}),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to range allocator: %w", err)
 	}
 
+	return NewClientWithConn(conn), nil
+}
+
+// NewClientWithConn wraps an existing connection, e.g. one to an in-process
+// server. Close closes conn.
+func NewClientWithConn(conn *grpc.ClientConn) *clientImpl {
 	return &clientImpl{
 		conn:   conn,
 		client: pb.NewRangeAllocatorClient(conn),
-	}, nil
+	}
 }
 
 func (c *clientImpl) AllocateRange(ctx context.Context, serviceID string, size *int64, region *string) (*pb.Range, error) {
@@ -62,6 +89,15 @@ func (c *clientImpl) AllocateRange(ctx context.Context, serviceID string, size *
 	return resp.Range, nil
 }
 
+func (c *clientImpl) GetRange(ctx context.Context, rangeID string) (*pb.Range, error) {
+	resp, err := c.client.GetRange(ctx, &pb.GetRangeRequest{RangeId: rangeID})
+	if err != nil {
+		return nil, fmt.Errorf("get range failed: %w", err)
+	}
+
+	return resp, nil
+}
+
 func (c *clientImpl) UpdateRangeStatus(ctx context.Context, rangeID, serviceID string, status pb.RangeStatus) (*pb.Range, error) {
 	req := &pb.UpdateRangeStatusRequest{
 		RangeId:   rangeID,
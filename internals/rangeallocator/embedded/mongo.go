@@ -0,0 +1,223 @@
+// Package embedded allocates ID ranges from gotiny's own MongoDB instead of
+// the range allocator service, for deployments that want a single binary.
+package embedded
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/RajNykDhulapkar/gotiny-range-allocator/pkg/pb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type Config struct {
+	URI      string
+	Database string
+	// CounterCollection holds one document per counter with the number of IDs
+	// handed out so far; RangeCollection records every range.
+	CounterCollection string
+	RangeCollection   string
+	CounterName       string
+	// FirstID is the first ID ever handed out. When migrating from the range
+	// allocator service it must be above the highest ID it allocated. It is
+	// stored in the counter document on the first allocation; changing it
+	// later has no effect.
+	FirstID     int64
+	DefaultSize int64
+	MinSize     int64
+	MaxSize     int64
+}
+
+func DefaultConfig() *Config {
+	return &Config{
+		CounterCollection: "counters",
+		RangeCollection:   "id_ranges",
+		CounterName:       "url_ids",
+		FirstID:           1,
+		DefaultSize:       1000,
+		MinSize:           1,
+		MaxSize:           1000000,
+	}
+}
+
+type counterDocument struct {
+	ID      string `bson:"_id"`
+	FirstID int64  `bson:"first_id"`
+	Used    int64  `bson:"used"`
+}
+
+type rangeDocument struct {
+	ID          string    `bson:"_id"`
+	StartID     int64     `bson:"start_id"`
+	EndID       int64     `bson:"end_id"`
+	ServiceID   string    `bson:"service_id"`
+	Region      string    `bson:"region"`
+	Status      string    `bson:"status"`
+	AllocatedAt time.Time `bson:"allocated_at"`
+	UpdatedAt   time.Time `bson:"updated_at"`
+}
+
+func (d *rangeDocument) toProto() *pb.Range {
+	return &pb.Range{
+		RangeId:     d.ID,
+		StartId:     d.StartID,
+		EndId:       d.EndID,
+		ServiceId:   d.ServiceID,
+		Region:      d.Region,
+		Status:      pb.RangeStatus(pb.RangeStatus_value[d.Status]),
+		AllocatedAt: timestamppb.New(d.AllocatedAt),
+		UpdatedAt:   timestamppb.New(d.UpdatedAt),
+	}
+}
+
+// MongoAllocator implements rangeallocator.Client. Errors carry gRPC status
+// codes like the real service's, so retries and circuit breaking work the
+// same way.
+type MongoAllocator struct {
+	client   *mongo.Client
+	counters *mongo.Collection
+	ranges   *mongo.Collection
+	config   *Config
+}
+
+func NewMongoAllocator(cfg *Config) (*MongoAllocator, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(cfg.URI))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to MongoDB: %w", err)
+	}
+
+	if err := client.Ping(ctx, nil); err != nil {
+		return nil, fmt.Errorf("failed to ping MongoDB: %w", err)
+	}
+
+	db := client.Database(cfg.Database)
+	return &MongoAllocator{
+		client:   client,
+		counters: db.Collection(cfg.CounterCollection),
+		ranges:   db.Collection(cfg.RangeCollection),
+		config:   cfg,
+	}, nil
+}
+
+// AllocateRange reserves exactly size IDs by atomically advancing the counter
+// document, so concurrent replicas never get overlapping ranges.
+func (a *MongoAllocator) AllocateRange(ctx context.Context, serviceID string, size *int64, region *string) (*pb.Range, error) {
+	if serviceID == "" {
+		return nil, status.Error(codes.InvalidArgument, "service_id is required")
+	}
+
+	n := a.config.DefaultSize
+	if size != nil {
+		n = *size
+		if n < a.config.MinSize || n > a.config.MaxSize {
+			return nil, status.Errorf(codes.InvalidArgument, "range size must be between %d and %d", a.config.MinSize, a.config.MaxSize)
+		}
+	}
+
+	regionName := "default"
+	if region != nil && *region != "" {
+		regionName = *region
+	}
+
+	var counter counterDocument
+	err := a.counters.FindOneAndUpdate(ctx,
+		bson.M{"_id": a.config.CounterName},
+		// Counters created before first_id was stored get the configured
+		// FirstID, which is what their ranges were based on.
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{
+			"first_id": bson.M{"$ifNull": bson.A{"$first_id", a.config.FirstID}},
+			"used":     bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$used", 0}}, n}},
+		}}}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&counter)
+	if err != nil {
+		return nil, statusError("allocate range failed", err)
+	}
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	start := counter.FirstID + counter.Used - n
+	doc := &rangeDocument{
+		ID:          primitive.NewObjectID().Hex(),
+		StartID:     start,
+		EndID:       start + n - 1,
+		ServiceID:   serviceID,
+		Region:      regionName,
+		Status:      pb.RangeStatus_RANGE_STATUS_ACTIVE.String(),
+		AllocatedAt: now,
+		UpdatedAt:   now,
+	}
+	if _, err := a.ranges.InsertOne(ctx, doc); err != nil {
+		// The IDs stay reserved by the counter and are simply never used.
+		return nil, statusError("record range failed", err)
+	}
+
+	return doc.toProto(), nil
+}
+
+func (a *MongoAllocator) GetRange(ctx context.Context, rangeID string) (*pb.Range, error) {
+	var doc rangeDocument
+	if err := a.ranges.FindOne(ctx, bson.M{"_id": rangeID}).Decode(&doc); err != nil {
+		return nil, statusError("get range failed", err)
+	}
+	return doc.toProto(), nil
+}
+
+func (a *MongoAllocator) UpdateRangeStatus(ctx context.Context, rangeID, serviceID string, rangeStatus pb.RangeStatus) (*pb.Range, error) {
+	switch rangeStatus {
+	case pb.RangeStatus_RANGE_STATUS_ACTIVE, pb.RangeStatus_RANGE_STATUS_EXHAUSTED, pb.RangeStatus_RANGE_STATUS_RELEASED:
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "invalid status: %s", rangeStatus)
+	}
+
+	var doc rangeDocument
+	err := a.ranges.FindOneAndUpdate(ctx,
+		bson.M{"_id": rangeID, "service_id": serviceID},
+		bson.M{"$set": bson.M{"status": rangeStatus.String(), "updated_at": time.Now().UTC()}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&doc)
+	if err != nil {
+		return nil, statusError("update range status failed", err)
+	}
+	return doc.toProto(), nil
+}
+
+func (a *MongoAllocator) GetHealth(ctx context.Context) error {
+	if err := a.client.Ping(ctx, nil); err != nil {
+		return fmt.Errorf("health check failed: %w", err)
+	}
+	return nil
+}
+
+func (a *MongoAllocator) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return a.client.Disconnect(ctx)
+}
+
+// statusError maps MongoDB errors to the status codes the range allocator
+// service would return.
+func statusError(msg string, err error) error {
+	code := codes.Internal
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		code = codes.NotFound
+	case errors.Is(err, context.DeadlineExceeded):
+		code = codes.DeadlineExceeded
+	case errors.Is(err, context.Canceled):
+		code = codes.Canceled
+	case mongo.IsNetworkError(err) || mongo.IsTimeout(err):
+		code = codes.Unavailable
+	}
+	return status.Errorf(code, "%s: %v", msg, err)
+}
@@ -0,0 +1,53 @@
+package fake
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/RajNykDhulapkar/gotiny-range-allocator/pkg/pb"
+	"github.com/RajNykDhulapkar/gotiny/internals/rangeallocator"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const bufSize = 1 << 20
+
+// client stops the in-process gRPC server when it is closed.
+type client struct {
+	rangeallocator.Client
+	server *grpc.Server
+}
+
+func (c *client) Close() error {
+	err := c.Client.Close()
+	c.server.Stop()
+	return err
+}
+
+// Dial serves s over an in-memory bufconn listener and returns a client
+// connected to it, going through the same gRPC stack as the real allocator.
+// Closing the client stops the server.
+func Dial(s *Server) (rangeallocator.Client, error) {
+	listener := bufconn.Listen(bufSize)
+	server := grpc.NewServer()
+	pb.RegisterRangeAllocatorServer(server, s)
+	go server.Serve(listener)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		server.Stop()
+		return nil, fmt.Errorf("failed to connect to fake range allocator: %w", err)
+	}
+
+	return &client{
+		Client: rangeallocator.NewClientWithConn(conn),
+		server: server,
+	}, nil
+}
@@ -0,0 +1,25 @@
+package fake
+
+import (
+	"context"
+	"testing"
+
+	"github.com/RajNykDhulapkar/gotiny/internals/rangeallocator"
+)
+
+// NewRangeManager returns a range manager backed by s. The manager and its
+// connection are closed when the test finishes.
+func (s *Server) NewRangeManager(tb testing.TB, config *rangeallocator.RangeManagerConfig) *rangeallocator.RangeManager {
+	tb.Helper()
+
+	client, err := Dial(s)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	manager := rangeallocator.NewRangeManager(client, config)
+	tb.Cleanup(func() {
+		manager.Close(context.Background())
+		client.Close()
+	})
+	return manager
+}
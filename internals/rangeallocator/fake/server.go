@@ -0,0 +1,181 @@
+// Package fake provides an in-memory range allocator for tests and local
+// development. It follows the real service's rules but keeps no state across
+// restarts, so IDs start over every time it is created.
+package fake
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/RajNykDhulapkar/gotiny-range-allocator/pkg/pb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var errUnavailable = status.Error(codes.Unavailable, "fake allocator marked unavailable")
+
+type Config struct {
+	// FirstID is the start of the first range handed out.
+	FirstID     int64
+	DefaultSize int64
+	MinSize     int64
+	MaxSize     int64
+}
+
+func DefaultConfig() *Config {
+	return &Config{
+		FirstID:     1,
+		DefaultSize: 1000,
+		MinSize:     1,
+		MaxSize:     1000000,
+	}
+}
+
+// Server implements pb.RangeAllocatorServer in memory.
+type Server struct {
+	pb.UnimplementedRangeAllocatorServer
+
+	config *Config
+
+	mu      sync.Mutex
+	nextID  int64
+	ranges  map[string]*pb.Range
+	order   []string
+	healthy bool
+	// unavailable makes the range calls fail with codes.Unavailable.
+	unavailable bool
+}
+
+func NewServer(config *Config) *Server {
+	if config == nil {
+		config = DefaultConfig()
+	}
+
+	return &Server{
+		config:  config,
+		nextID:  config.FirstID,
+		ranges:  make(map[string]*pb.Range),
+		healthy: true,
+	}
+}
+
+// AllocateRange hands out consecutive ranges. Like the real allocator, a range
+// of size n covers StartId to StartId+n inclusive.
+func (s *Server) AllocateRange(ctx context.Context, req *pb.AllocateRangeRequest) (*pb.AllocateRangeResponse, error) {
+	if req.ServiceId == "" {
+		return nil, status.Error(codes.InvalidArgument, "service_id is required")
+	}
+
+	size := s.config.DefaultSize
+	if req.Size != nil {
+		size = *req.Size
+		if size < s.config.MinSize || size > s.config.MaxSize {
+			return nil, status.Errorf(codes.InvalidArgument, "range size must be between %d and %d", s.config.MinSize, s.config.MaxSize)
+		}
+	}
+
+	region := "default"
+	if req.Region != nil && *req.Region != "" {
+		region = *req.Region
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.unavailable {
+		return nil, errUnavailable
+	}
+
+	now := timestamppb.Now()
+	r := &pb.Range{
+		RangeId:     fmt.Sprintf("fake-%d", len(s.order)+1),
+		StartId:     s.nextID,
+		EndId:       s.nextID + size,
+		ServiceId:   req.ServiceId,
+		Region:      region,
+		Status:      pb.RangeStatus_RANGE_STATUS_ACTIVE,
+		AllocatedAt: now,
+		UpdatedAt:   now,
+	}
+	s.nextID = r.EndId + 1
+	s.ranges[r.RangeId] = r
+	s.order = append(s.order, r.RangeId)
+
+	return &pb.AllocateRangeResponse{Range: proto.Clone(r).(*pb.Range)}, nil
+}
+
+func (s *Server) GetRange(ctx context.Context, req *pb.GetRangeRequest) (*pb.Range, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.unavailable {
+		return nil, errUnavailable
+	}
+
+	r, ok := s.ranges[req.RangeId]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "range %s not found", req.RangeId)
+	}
+	return proto.Clone(r).(*pb.Range), nil
+}
+
+func (s *Server) UpdateRangeStatus(ctx context.Context, req *pb.UpdateRangeStatusRequest) (*pb.Range, error) {
+	switch req.Status {
+	case pb.RangeStatus_RANGE_STATUS_ACTIVE, pb.RangeStatus_RANGE_STATUS_EXHAUSTED, pb.RangeStatus_RANGE_STATUS_RELEASED:
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "invalid status: %s", req.Status)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.unavailable {
+		return nil, errUnavailable
+	}
+
+	r, ok := s.ranges[req.RangeId]
+	if !ok || r.ServiceId != req.ServiceId {
+		return nil, status.Errorf(codes.NotFound, "range %s not found for service %s", req.RangeId, req.ServiceId)
+	}
+	r.Status = req.Status
+	r.UpdatedAt = timestamppb.Now()
+	return proto.Clone(r).(*pb.Range), nil
+}
+
+func (s *Server) GetHealth(ctx context.Context, _ *emptypb.Empty) (*pb.HealthResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.healthy {
+		return &pb.HealthResponse{Status: pb.ServiceStatus_SERVICE_STATUS_NOT_SERVING, Details: "fake allocator marked unhealthy"}, nil
+	}
+	return &pb.HealthResponse{Status: pb.ServiceStatus_SERVICE_STATUS_SERVING, Details: "fake allocator"}, nil
+}
+
+// SetHealthy changes what GetHealth reports.
+func (s *Server) SetHealthy(healthy bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.healthy = healthy
+}
+
+// SetUnavailable makes AllocateRange, GetRange and UpdateRangeStatus fail with
+// codes.Unavailable, like an allocator that cannot be reached.
+func (s *Server) SetUnavailable(unavailable bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.unavailable = unavailable
+}
+
+// Ranges returns every range allocated so far, oldest first.
+func (s *Server) Ranges() []*pb.Range {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ranges := make([]*pb.Range, 0, len(s.order))
+	for _, id := range s.order {
+		ranges = append(ranges, proto.Clone(s.ranges[id]).(*pb.Range))
+	}
+	return ranges
+}
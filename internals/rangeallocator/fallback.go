@@ -0,0 +1,93 @@
+package rangeallocator
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Fallback IDs are laid out Snowflake-style in 62 bits:
+//
+//	1 bit flag | 39 bits milliseconds since epoch | 10 bits node | 12 bits sequence
+//
+// The flag bit keeps them disjoint from allocator ranges, which never reach
+// 2^61.
+const (
+	fallbackNodeBits     = 10
+	fallbackSequenceBits = 12
+	fallbackTimeBits     = 39
+
+	FallbackIDFlag      int64 = 1 << (fallbackTimeBits + fallbackNodeBits + fallbackSequenceBits)
+	MaxFallbackNodeID   int64 = 1<<fallbackNodeBits - 1
+	maxFallbackSequence       = 1<<fallbackSequenceBits - 1
+	maxFallbackTime           = 1<<fallbackTimeBits - 1
+)
+
+var (
+	DefaultFallbackEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	ErrInvalidNodeID = errors.New("invalid fallback node id")
+)
+
+// FallbackGenerator issues IDs without the range allocator. IDs are unique as
+// long as every replica has its own node ID.
+type FallbackGenerator struct {
+	mu       sync.Mutex
+	nodeID   int64
+	epoch    time.Time
+	lastTime int64
+	sequence int64
+}
+
+func NewFallbackGenerator(nodeID int64, epoch time.Time) (*FallbackGenerator, error) {
+	if nodeID < 0 || nodeID > MaxFallbackNodeID {
+		return nil, fmt.Errorf("%w: %d is not between 0 and %d", ErrInvalidNodeID, nodeID, MaxFallbackNodeID)
+	}
+	if epoch.IsZero() {
+		epoch = DefaultFallbackEpoch
+	}
+
+	return &FallbackGenerator{
+		nodeID: nodeID,
+		epoch:  epoch,
+	}, nil
+}
+
+func (g *FallbackGenerator) NextID() (int64, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := time.Since(g.epoch).Milliseconds()
+	if ms < 0 {
+		return 0, fmt.Errorf("clock is before the fallback epoch %s", g.epoch.Format(time.RFC3339))
+	}
+
+	// If the clock moves backwards, or a millisecond runs out of sequence
+	// numbers, keep counting from the last timestamp used rather than waiting.
+	if ms <= g.lastTime {
+		ms = g.lastTime
+		if g.sequence == maxFallbackSequence {
+			ms++
+			g.sequence = 0
+		} else {
+			g.sequence++
+		}
+	} else {
+		g.sequence = 0
+	}
+	if ms > maxFallbackTime {
+		return 0, fmt.Errorf("fallback id timestamp overflowed %d bits", fallbackTimeBits)
+	}
+	g.lastTime = ms
+
+	return FallbackIDFlag |
+		ms<<(fallbackNodeBits+fallbackSequenceBits) |
+		g.nodeID<<fallbackSequenceBits |
+		g.sequence, nil
+}
+
+// IsFallbackID reports whether id was issued by a FallbackGenerator.
+func IsFallbackID(id int64) bool {
+	return id&FallbackIDFlag != 0
+}
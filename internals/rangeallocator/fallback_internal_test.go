@@ -0,0 +1,72 @@
+package rangeallocator
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFallbackSequenceRollover(t *testing.T) {
+	g, err := NewFallbackGenerator(3, DefaultFallbackEpoch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Pretend the last ID was issued an hour from now with the millisecond's
+	// sequence almost used up, as after the clock stepped back.
+	future := time.Since(DefaultFallbackEpoch).Milliseconds() + time.Hour.Milliseconds()
+	g.lastTime = future
+	g.sequence = maxFallbackSequence - 1
+
+	type fields struct{ ms, node, sequence int64 }
+	want := []fields{
+		{future, 3, maxFallbackSequence},
+		{future + 1, 3, 0},
+		{future + 1, 3, 1},
+	}
+
+	var last int64
+	for i, w := range want {
+		id, err := g.NextID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !IsFallbackID(id) {
+			t.Fatalf("ID %d is missing the fallback flag", id)
+		}
+		got := fields{
+			ms:       (id &^ FallbackIDFlag) >> (fallbackNodeBits + fallbackSequenceBits),
+			node:     id >> fallbackSequenceBits & MaxFallbackNodeID,
+			sequence: id & maxFallbackSequence,
+		}
+		if got != w {
+			t.Errorf("ID %d: got %+v, want %+v", i, got, w)
+		}
+		if id <= last {
+			t.Errorf("ID %d is not above the previous ID %d", id, last)
+		}
+		last = id
+	}
+}
+
+func TestFallbackNodeIDs(t *testing.T) {
+	for _, nodeID := range []int64{-1, MaxFallbackNodeID + 1} {
+		if _, err := NewFallbackGenerator(nodeID, DefaultFallbackEpoch); err == nil {
+			t.Errorf("node ID %d was accepted", nodeID)
+		}
+	}
+
+	a, _ := NewFallbackGenerator(1, DefaultFallbackEpoch)
+	b, _ := NewFallbackGenerator(2, DefaultFallbackEpoch)
+	seen := make(map[int64]bool)
+	for i := 0; i < 10000; i++ {
+		for _, g := range []*FallbackGenerator{a, b} {
+			id, err := g.NextID()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if seen[id] {
+				t.Fatalf("ID %d was issued twice", id)
+			}
+			seen[id] = true
+		}
+	}
+}
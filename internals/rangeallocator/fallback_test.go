@@ -0,0 +1,67 @@
+package rangeallocator_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/RajNykDhulapkar/gotiny/internals/rangeallocator"
+	"github.com/RajNykDhulapkar/gotiny/internals/rangeallocator/fake"
+)
+
+func TestFallbackIDsDisjointFromRanges(t *testing.T) {
+	fallback, err := rangeallocator.NewFallbackGenerator(7, rangeallocator.DefaultFallbackEpoch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := fake.NewServer(fake.DefaultConfig())
+	manager := server.NewRangeManager(t, &rangeallocator.RangeManagerConfig{
+		ServiceID: "test",
+		RangeSize: 5,
+		Fallback:  fallback,
+	})
+
+	seen := make(map[int64]bool)
+	next := func() int64 {
+		t.Helper()
+		id, err := manager.GetNextID(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seen[id] {
+			t.Fatalf("ID %d was handed out twice", id)
+		}
+		seen[id] = true
+		return id
+	}
+
+	for i := 0; i < 20; i++ {
+		if id := next(); rangeallocator.IsFallbackID(id) {
+			t.Fatalf("range ID %d looks like a fallback ID", id)
+		}
+	}
+
+	server.SetUnavailable(true)
+	fallbackIDs := 0
+	for i := 0; i < 20; i++ {
+		id := next()
+		if !rangeallocator.IsFallbackID(id) {
+			// The rest of the current range is used first.
+			continue
+		}
+		fallbackIDs++
+		if inSomeRange(server.Ranges(), id) {
+			t.Fatalf("fallback ID %d is inside an allocated range", id)
+		}
+	}
+	if fallbackIDs == 0 {
+		t.Fatal("no fallback IDs while the allocator was unavailable")
+	}
+	if got := manager.Stats().FallbackIDs; got != int64(fallbackIDs) {
+		t.Errorf("Stats().FallbackIDs = %d, want %d", got, fallbackIDs)
+	}
+
+	server.SetUnavailable(false)
+	if id := next(); rangeallocator.IsFallbackID(id) {
+		t.Fatalf("got fallback ID %d after the allocator came back", id)
+	}
+}
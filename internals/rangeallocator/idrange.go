@@ -0,0 +1,56 @@
+package rangeallocator
+
+import (
+	"sync/atomic"
+
+	"github.com/RajNykDhulapkar/gotiny-range-allocator/pkg/pb"
+	"google.golang.org/protobuf/proto"
+)
+
+// idRange hands out the IDs of an allocated range, StartId to EndId
+// inclusive. The range message is never modified; progress is tracked by
+// cursor, the next ID to hand out, which only ever moves forward.
+type idRange struct {
+	rng    *pb.Range
+	end    int64
+	size   int64
+	cursor atomic.Int64
+}
+
+// newIDRange starts handing out IDs at next, which is past StartId when a
+// checkpointed range is resumed.
+func newIDRange(rng *pb.Range, next int64) *idRange {
+	r := &idRange{
+		rng:  rng,
+		end:  rng.EndId,
+		size: rng.EndId - rng.StartId + 1,
+	}
+	r.cursor.Store(next)
+	return r
+}
+
+// next claims the next ID. It reports false once the range is exhausted or
+// sealed; the cursor keeps moving past the end, which is harmless.
+func (r *idRange) next() (int64, bool) {
+	id := r.cursor.Add(1) - 1
+	return id, id <= r.end
+}
+
+func (r *idRange) remaining() int64 {
+	return max(r.end-r.cursor.Load()+1, 0)
+}
+
+// seal stops the range from handing out any more IDs and returns the first
+// ID that was not handed out. IDs claimed concurrently are either before it
+// or rejected.
+func (r *idRange) seal() int64 {
+	return min(r.cursor.Swap(r.end+1), r.end+1)
+}
+
+// snapshot returns a copy of the range message whose StartId is the next ID
+// to hand out.
+func (r *idRange) snapshot() *pb.Range {
+	rng := proto.Clone(r.rng).(*pb.Range)
+	rng.StartId = min(r.cursor.Load(), r.end+1)
+	return rng
+}
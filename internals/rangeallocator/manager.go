@@ -1,103 +1,392 @@
 package rangeallocator
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/RajNykDhulapkar/gotiny-range-allocator/pkg/pb"
 )
 
+const (
+	prefetchTimeout = 10 * time.Second
+	defaultRegion   = "default"
+)
+
+var ErrManagerClosed = errors.New("range manager is closed")
+
 type RangeManagerConfig struct {
 	ServiceID string
 	RangeSize int64
 	Region    string
+	// PrefetchThreshold is the fraction of the current range left when the
+	// next range is requested in the background, e.g. 0.2. Zero disables
+	// prefetching.
+	PrefetchThreshold float64
+	// TargetRefillInterval enables adaptive range sizing: ranges are sized to
+	// last about this long at the observed consumption rate, bounded by
+	// MinRangeSize and MaxRangeSize. Zero always requests RangeSize.
+	TargetRefillInterval time.Duration
+	MinRangeSize         int64
+	MaxRangeSize         int64
+	// CheckpointPath, if set, is where Close saves the unused part of the
+	// current range instead of releasing it, so Restore can resume it after a
+	// quick restart.
+	CheckpointPath string
+	// CheckpointMaxAge is how old a checkpoint may be and still be resumed.
+	// Older checkpoints are released. Zero means no limit.
+	CheckpointMaxAge time.Duration
+	// Fallback, if set, issues IDs while the range allocator is unreachable,
+	// including while the client's circuit breaker rejects calls.
+	Fallback *FallbackGenerator
+}
+
+// Validate checks the range sizes; NewRangeManager does not.
+func (c *RangeManagerConfig) Validate() error {
+	if c.RangeSize < 1 {
+		return fmt.Errorf("range size must be at least 1, got %d", c.RangeSize)
+	}
+	if c.TargetRefillInterval <= 0 {
+		if c.MinRangeSize != 0 || c.MaxRangeSize != 0 {
+			return errors.New("minimum and maximum range sizes only apply with a target refill interval")
+		}
+		return nil
+	}
+	if c.MinRangeSize < 1 {
+		return fmt.Errorf("minimum range size must be at least 1, got %d", c.MinRangeSize)
+	}
+	if c.MaxRangeSize < c.MinRangeSize {
+		return fmt.Errorf("maximum range size %d is below the minimum %d", c.MaxRangeSize, c.MinRangeSize)
+	}
+	return nil
+}
+
+// RangeManagerStats counts how ranges were obtained. SyncAllocations are the
+// requests that still had to wait for the range allocator.
+type RangeManagerStats struct {
+	Prefetches       int64 `json:"prefetches"`
+	PrefetchFailures int64 `json:"prefetch_failures"`
+	PrefetchedSwaps  int64 `json:"prefetched_swaps"`
+	SyncAllocations  int64 `json:"sync_allocations"`
+	// ConsumptionRate is the smoothed number of IDs issued per second.
+	ConsumptionRate   float64 `json:"consumption_rate"`
+	LastRequestedSize int64   `json:"last_requested_size"`
+	// FallbackIDs counts IDs issued by the fallback generator.
+	FallbackIDs int64 `json:"fallback_ids"`
+	// AllocatorState is the client's circuit breaker state; always closed
+	// for clients without one.
+	AllocatorState BreakerState `json:"allocator_state"`
+}
+
+// breakerClient is a Client with a circuit breaker, such as ResilientClient.
+type breakerClient interface {
+	Client
+	State() BreakerState
 }
 
+// RangeManager hands out IDs from ranges leased from the range allocator.
+// GetNextID only takes mu when the current range runs out; mu serializes
+// swapping in the next range, the prefetched range and closing.
 type RangeManager struct {
-	client       Client
-	config       *RangeManagerConfig
-	mu           sync.RWMutex
-	currentRange *pb.Range
+	client  Client
+	config  *RangeManagerConfig
+	current atomic.Pointer[idRange]
+	sizer   *rangeSizer
+	issued  atomic.Int64
+	// prefetched is set while a prefetch is running or its range is waiting
+	// in nextRange.
+	prefetched atomic.Bool
+
+	mu        sync.Mutex
+	nextRange *pb.Range
+	closed    bool
+
+	lastRequestedSize atomic.Int64
+	prefetches        atomic.Int64
+	prefetchFailures  atomic.Int64
+	prefetchedSwaps   atomic.Int64
+	syncAllocations   atomic.Int64
+	fallbackIDs       atomic.Int64
 }
 
 func NewRangeManager(client Client, config *RangeManagerConfig) *RangeManager {
 	return &RangeManager{
 		client: client,
 		config: config,
+		sizer:  newRangeSizer(config),
 	}
 }
 
 func (rm *RangeManager) GetNextID(ctx context.Context) (int64, error) {
-	rm.mu.RLock()
-	if rm.currentRange == nil {
-		rm.mu.RUnlock()
-		return rm.allocateNewRange(ctx)
-	}
-
-	currentID := atomic.LoadInt64(&rm.currentRange.StartId)
-	if currentID >= rm.currentRange.EndId {
-		rm.mu.RUnlock()
-		return rm.allocateNewRange(ctx)
-	}
-
-	nextID := atomic.AddInt64(&rm.currentRange.StartId, 1)
-	rm.mu.RUnlock()
-
-	if nextID > rm.currentRange.EndId {
-		rm.mu.Lock()
-		if rm.currentRange.StartId > rm.currentRange.EndId {
-			// Mark current range as exhausted
-			_, err := rm.client.UpdateRangeStatus(ctx,
-				rm.currentRange.RangeId,
-				rm.config.ServiceID,
-				pb.RangeStatus_RANGE_STATUS_EXHAUSTED,
-			)
-			if err != nil {
-				rm.mu.Unlock()
-				return 0, fmt.Errorf("failed to update range status: %w", err)
-			}
-			rm.mu.Unlock()
-			return rm.allocateNewRange(ctx)
+	if r := rm.current.Load(); r != nil {
+		if id, ok := r.next(); ok {
+			rm.issued.Add(1)
+			rm.maybePrefetch(r)
+			return id, nil
 		}
-		rm.mu.Unlock()
+	}
+	return rm.allocateNewRange(ctx)
+}
+
+// maybePrefetch starts a background request for the next range once the
+// remaining IDs drop below the configured threshold.
+func (rm *RangeManager) maybePrefetch(r *idRange) {
+	if rm.config.PrefetchThreshold <= 0 {
+		return
+	}
+	if float64(r.remaining()) > rm.config.PrefetchThreshold*float64(r.size) {
+		return
+	}
+	if !rm.prefetched.CompareAndSwap(false, true) {
+		return
+	}
+
+	go rm.prefetch()
+}
+
+func (rm *RangeManager) prefetch() {
+	// The client would reject the call anyway; the next GetNextID that runs
+	// out of IDs falls back instead.
+	if rm.allocatorState() == BreakerOpen {
+		rm.prefetched.Store(false)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), prefetchTimeout)
+	defer cancel()
+
+	newRange, err := rm.requestRange(ctx)
+	if err != nil {
+		rm.prefetchFailures.Add(1)
+		rm.prefetched.Store(false)
+		return
 	}
 
-	return nextID - 1, nil
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	if rm.closed {
+		rm.release(ctx, newRange)
+		return
+	}
+	rm.nextRange = newRange
+	rm.prefetches.Add(1)
 }
 
 func (rm *RangeManager) allocateNewRange(ctx context.Context) (int64, error) {
 	rm.mu.Lock()
 	defer rm.mu.Unlock()
 
-	if rm.currentRange != nil && rm.currentRange.StartId < rm.currentRange.EndId {
-		return atomic.AddInt64(&rm.currentRange.StartId, 1) - 1, nil
+	for {
+		// Another caller may have swapped in a new range while we waited.
+		old := rm.current.Load()
+		if old != nil {
+			if id, ok := old.next(); ok {
+				rm.issued.Add(1)
+				return id, nil
+			}
+		}
+		if rm.closed {
+			return 0, ErrManagerClosed
+		}
+
+		newRange := rm.nextRange
+		if newRange != nil {
+			rm.nextRange = nil
+			rm.prefetched.Store(false)
+			rm.prefetchedSwaps.Add(1)
+		} else {
+			var err error
+			if newRange, err = rm.requestRange(ctx); err != nil {
+				return rm.fallbackID(fmt.Errorf("failed to allocate new range: %w", err))
+			}
+			rm.syncAllocations.Add(1)
+		}
+
+		rm.current.Store(newIDRange(newRange, newRange.StartId))
+		if old != nil {
+			go rm.markExhausted(old.rng)
+		}
+	}
+}
+
+// markExhausted tells the allocator a range has been used up. It is only
+// informational, so failures are ignored.
+func (rm *RangeManager) markExhausted(r *pb.Range) {
+	ctx, cancel := context.WithTimeout(context.Background(), prefetchTimeout)
+	defer cancel()
+
+	_, _ = rm.client.UpdateRangeStatus(ctx,
+		r.RangeId,
+		rm.config.ServiceID,
+		pb.RangeStatus_RANGE_STATUS_EXHAUSTED,
+	)
+}
+
+// fallbackID issues an ID from the fallback generator, or returns cause if
+// there is none.
+func (rm *RangeManager) fallbackID(cause error) (int64, error) {
+	if rm.config.Fallback == nil {
+		return 0, cause
 	}
 
+	id, err := rm.config.Fallback.NextID()
+	if err != nil {
+		return 0, fmt.Errorf("%w; fallback failed: %w", cause, err)
+	}
+	rm.fallbackIDs.Add(1)
+	return id, nil
+}
+
+func (rm *RangeManager) requestRange(ctx context.Context) (*pb.Range, error) {
 	var region *string
 	if rm.config.Region != "" {
 		region = &rm.config.Region
 	}
 
-	size := rm.config.RangeSize
-	newRange, err := rm.client.AllocateRange(ctx, rm.config.ServiceID, &size, region)
+	size := rm.sizer.nextSize(rm.issued.Load(), rm.config.RangeSize, time.Now())
+	rm.lastRequestedSize.Store(size)
+	return rm.client.AllocateRange(ctx, rm.config.ServiceID, &size, region)
+}
+
+// Restore resumes the range saved by a previous Close. The checkpoint is
+// consumed either way, and is only resumed if it belongs to this service, is
+// not older than CheckpointMaxAge and the allocator still reports the range as
+// active and ours. A range saved for another region is released, since its IDs
+// may collide with codes issued in this one. Call it before the manager hands
+// out any IDs.
+func (rm *RangeManager) Restore(ctx context.Context) error {
+	if rm.config.CheckpointPath == "" {
+		return nil
+	}
+
+	cp, err := takeCheckpoint(rm.config.CheckpointPath)
+	if err != nil || cp == nil {
+		return err
+	}
+	if cp.ServiceID != rm.config.ServiceID || cp.NextID > cp.EndID {
+		return nil
+	}
+
+	saved, err := rm.client.GetRange(ctx, cp.RangeID)
 	if err != nil {
-		return 0, fmt.Errorf("failed to allocate new range: %w", err)
+		return fmt.Errorf("failed to verify checkpointed range: %w", err)
+	}
+	if saved.Status != pb.RangeStatus_RANGE_STATUS_ACTIVE ||
+		saved.ServiceId != rm.config.ServiceID ||
+		cp.NextID < saved.StartId || cp.EndID != saved.EndId {
+		return nil
+	}
+	// Allocators put ranges requested without a region in "default".
+	region := cmp.Or(rm.config.Region, defaultRegion)
+	if cp.Region != region || saved.Region != region {
+		return rm.release(ctx, saved)
+	}
+	if rm.config.CheckpointMaxAge > 0 && time.Since(cp.SavedAt) > rm.config.CheckpointMaxAge {
+		return rm.release(ctx, saved)
 	}
 
-	rm.currentRange = newRange
-	return atomic.AddInt64(&rm.currentRange.StartId, 1) - 1, nil
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	if !rm.closed {
+		rm.current.CompareAndSwap(nil, newIDRange(saved, cp.NextID))
+	}
+	return nil
 }
 
-func (rm *RangeManager) GetCurrentRange() *pb.Range {
-	rm.mu.RLock()
-	defer rm.mu.RUnlock()
+// Close gives back the IDs the manager has not handed out. A prefetched range
+// is released. The rest of the current range is checkpointed when
+// CheckpointPath is set, and released otherwise or if the checkpoint cannot be
+// written. GetNextID fails with ErrManagerClosed afterwards.
+func (rm *RangeManager) Close(ctx context.Context) error {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
 
-	if rm.currentRange == nil {
+	if rm.closed {
 		return nil
 	}
+	rm.closed = true
 
-	rangeCopy := *rm.currentRange
-	return &rangeCopy
+	var errs []error
+	if rm.nextRange != nil {
+		errs = append(errs, rm.release(ctx, rm.nextRange))
+		rm.nextRange = nil
+	}
+
+	current := rm.current.Load()
+	if current == nil {
+		return errors.Join(errs...)
+	}
+	// Sealing stops concurrent GetNextID calls from claiming IDs that are
+	// about to be checkpointed or released.
+	nextID := current.seal()
+	if nextID > current.end {
+		return errors.Join(errs...)
+	}
+
+	if rm.config.CheckpointPath == "" {
+		errs = append(errs, rm.release(ctx, current.rng))
+		return errors.Join(errs...)
+	}
+
+	err := writeCheckpoint(rm.config.CheckpointPath, &checkpoint{
+		RangeID:   current.rng.RangeId,
+		ServiceID: rm.config.ServiceID,
+		Region:    current.rng.Region,
+		NextID:    nextID,
+		EndID:     current.end,
+		SavedAt:   time.Now(),
+	})
+	if err != nil {
+		errs = append(errs, err, rm.release(ctx, current.rng))
+	}
+	return errors.Join(errs...)
+}
+
+// release tells the allocator a range will not be used any further. The
+// allocator API has no field for a partial cursor, so the whole range is
+// marked released.
+func (rm *RangeManager) release(ctx context.Context, r *pb.Range) error {
+	_, err := rm.client.UpdateRangeStatus(ctx,
+		r.RangeId,
+		rm.config.ServiceID,
+		pb.RangeStatus_RANGE_STATUS_RELEASED,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to release range %s: %w", r.RangeId, err)
+	}
+	return nil
+}
+
+// GetCurrentRange returns a copy of the range IDs are handed out from, with
+// StartId set to the next ID to hand out.
+func (rm *RangeManager) GetCurrentRange() *pb.Range {
+	r := rm.current.Load()
+	if r == nil {
+		return nil
+	}
+	return r.snapshot()
+}
+
+func (rm *RangeManager) Stats() RangeManagerStats {
+	return RangeManagerStats{
+		Prefetches:        rm.prefetches.Load(),
+		PrefetchFailures:  rm.prefetchFailures.Load(),
+		PrefetchedSwaps:   rm.prefetchedSwaps.Load(),
+		SyncAllocations:   rm.syncAllocations.Load(),
+		ConsumptionRate:   rm.sizer.currentRate(),
+		LastRequestedSize: rm.lastRequestedSize.Load(),
+		FallbackIDs:       rm.fallbackIDs.Load(),
+		AllocatorState:    rm.allocatorState(),
+	}
+}
+
+func (rm *RangeManager) allocatorState() BreakerState {
+	if c, ok := rm.client.(breakerClient); ok {
+		return c.State()
+	}
+	return BreakerClosed
 }
@@ -0,0 +1,135 @@
+package rangeallocator_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RajNykDhulapkar/gotiny-range-allocator/pkg/pb"
+	"github.com/RajNykDhulapkar/gotiny/internals/rangeallocator"
+	"github.com/RajNykDhulapkar/gotiny/internals/rangeallocator/fake"
+)
+
+// TestRangeManagerConcurrentIDs hammers a manager with small ranges and
+// prefetching from many goroutines; run it with -race.
+func TestRangeManagerConcurrentIDs(t *testing.T) {
+	const (
+		goroutines   = 32
+		idsPerWorker = 500
+	)
+
+	server := fake.NewServer(fake.DefaultConfig())
+	manager := server.NewRangeManager(t, &rangeallocator.RangeManagerConfig{
+		ServiceID:         "test",
+		RangeSize:         16,
+		PrefetchThreshold: 0.5,
+	})
+
+	ids := make(chan int64, goroutines*idsPerWorker)
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < idsPerWorker; j++ {
+				id, err := manager.GetNextID(context.Background())
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				ids <- id
+			}
+		}()
+	}
+	wg.Wait()
+	close(ids)
+
+	if err := manager.Close(context.Background()); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	ranges := server.Ranges()
+	seen := make(map[int64]bool, goroutines*idsPerWorker)
+	for id := range ids {
+		if seen[id] {
+			t.Fatalf("ID %d was handed out twice", id)
+		}
+		seen[id] = true
+
+		if !inSomeRange(ranges, id) {
+			t.Fatalf("ID %d is outside every allocated range", id)
+		}
+	}
+	if len(seen) != goroutines*idsPerWorker {
+		t.Fatalf("got %d IDs, want %d", len(seen), goroutines*idsPerWorker)
+	}
+
+	stats := manager.Stats()
+	if stats.Prefetches == 0 {
+		t.Errorf("no range was prefetched: %+v", stats)
+	}
+	if stats.FallbackIDs != 0 {
+		t.Errorf("issued %d fallback IDs", stats.FallbackIDs)
+	}
+}
+
+func TestRangeManagerCloseReleasesRanges(t *testing.T) {
+	server := fake.NewServer(fake.DefaultConfig())
+	manager := server.NewRangeManager(t, &rangeallocator.RangeManagerConfig{
+		ServiceID: "test",
+		RangeSize: 100,
+	})
+
+	if _, err := manager.GetNextID(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.Close(context.Background()); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	for _, r := range server.Ranges() {
+		if r.Status != pb.RangeStatus_RANGE_STATUS_RELEASED {
+			t.Errorf("range %s is %s after Close, want released", r.RangeId, r.Status)
+		}
+	}
+	if _, err := manager.GetNextID(context.Background()); err != rangeallocator.ErrManagerClosed {
+		t.Fatalf("GetNextID after Close: got %v, want ErrManagerClosed", err)
+	}
+}
+
+func TestRangeManagerConfigValidate(t *testing.T) {
+	adaptive := func(min, max int64) *rangeallocator.RangeManagerConfig {
+		return &rangeallocator.RangeManagerConfig{RangeSize: 1000, TargetRefillInterval: time.Minute, MinRangeSize: min, MaxRangeSize: max}
+	}
+
+	tests := []struct {
+		name    string
+		config  *rangeallocator.RangeManagerConfig
+		wantErr bool
+	}{
+		{"fixed", &rangeallocator.RangeManagerConfig{RangeSize: 1000}, false},
+		{"empty range", &rangeallocator.RangeManagerConfig{}, true},
+		{"bounds without target", &rangeallocator.RangeManagerConfig{RangeSize: 1000, MinRangeSize: 100}, true},
+		{"adaptive", adaptive(100, 10000), false},
+		{"single size", adaptive(100, 100), false},
+		{"zero minimum", adaptive(0, 10000), true},
+		{"negative minimum", adaptive(-1, 10000), true},
+		{"minimum above maximum", adaptive(1000, 100), true},
+	}
+
+	for _, tt := range tests {
+		if err := tt.config.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: got %v, want error: %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func inSomeRange(ranges []*pb.Range, id int64) bool {
+	for _, r := range ranges {
+		if r.StartId <= id && id <= r.EndId {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,182 @@
+package rangeallocator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+
+	"github.com/RajNykDhulapkar/gotiny-range-allocator/pkg/pb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var ErrCircuitOpen = errors.New("range allocator circuit is open")
+
+type ResilientConfig struct {
+	// CallTimeout bounds each attempt, on top of the caller's deadline.
+	CallTimeout time.Duration
+	// MaxAttempts is the number of tries for calls failing with a retryable
+	// gRPC code, spaced by jittered exponential backoff between
+	// InitialBackoff and MaxBackoff.
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// The breaker opens after BreakerThreshold consecutive failed calls and
+	// lets one probe through once BreakerCooldown has passed.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
+func DefaultResilientConfig() *ResilientConfig {
+	return &ResilientConfig{
+		CallTimeout:      2 * time.Second,
+		MaxAttempts:      3,
+		InitialBackoff:   50 * time.Millisecond,
+		MaxBackoff:       time.Second,
+		BreakerThreshold: 5,
+		BreakerCooldown:  10 * time.Second,
+	}
+}
+
+type ResilientClientStats struct {
+	State    BreakerState `json:"state"`
+	Retries  int64        `json:"retries"`
+	Failures int64        `json:"failures"`
+	Rejected int64        `json:"rejected"`
+}
+
+// ResilientClient wraps a Client with per-call timeouts, retries and a
+// circuit breaker.
+type ResilientClient struct {
+	client  Client
+	config  *ResilientConfig
+	breaker *breaker
+
+	retries  atomic.Int64
+	failures atomic.Int64
+	rejected atomic.Int64
+}
+
+func NewResilientClient(client Client, config *ResilientConfig) *ResilientClient {
+	if config == nil {
+		config = DefaultResilientConfig()
+	}
+
+	return &ResilientClient{
+		client:  client,
+		config:  config,
+		breaker: newBreaker(config.BreakerThreshold, config.BreakerCooldown),
+	}
+}
+
+// AllocateRange is retried like the other calls. A retry after a timeout may
+// allocate a second range while the first one is never used; that wastes IDs
+// but never hands out the same ID twice.
+func (c *ResilientClient) AllocateRange(ctx context.Context, serviceID string, size *int64, region *string) (*pb.Range, error) {
+	return call(ctx, c, func(ctx context.Context) (*pb.Range, error) {
+		return c.client.AllocateRange(ctx, serviceID, size, region)
+	})
+}
+
+func (c *ResilientClient) GetRange(ctx context.Context, rangeID string) (*pb.Range, error) {
+	return call(ctx, c, func(ctx context.Context) (*pb.Range, error) {
+		return c.client.GetRange(ctx, rangeID)
+	})
+}
+
+func (c *ResilientClient) UpdateRangeStatus(ctx context.Context, rangeID, serviceID string, status pb.RangeStatus) (*pb.Range, error) {
+	return call(ctx, c, func(ctx context.Context) (*pb.Range, error) {
+		return c.client.UpdateRangeStatus(ctx, rangeID, serviceID, status)
+	})
+}
+
+// GetHealth is neither retried nor stopped by the breaker, so it always
+// reports the allocator's current state.
+func (c *ResilientClient) GetHealth(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, c.config.CallTimeout)
+	defer cancel()
+	return c.client.GetHealth(ctx)
+}
+
+func (c *ResilientClient) Close() error {
+	return c.client.Close()
+}
+
+func (c *ResilientClient) State() BreakerState {
+	return c.breaker.State()
+}
+
+func (c *ResilientClient) Stats() ResilientClientStats {
+	return ResilientClientStats{
+		State:    c.breaker.State(),
+		Retries:  c.retries.Load(),
+		Failures: c.failures.Load(),
+		Rejected: c.rejected.Load(),
+	}
+}
+
+func call[T any](ctx context.Context, c *ResilientClient, fn func(context.Context) (T, error)) (T, error) {
+	var zero T
+	if !c.breaker.allow(time.Now()) {
+		c.rejected.Add(1)
+		return zero, ErrCircuitOpen
+	}
+
+	for attempt := 1; ; attempt++ {
+		callCtx, cancel := context.WithTimeout(ctx, c.config.CallTimeout)
+		resp, err := fn(callCtx)
+		cancel()
+
+		switch {
+		case err == nil:
+			c.breaker.success()
+			return resp, nil
+		case ctx.Err() != nil:
+			c.breaker.abandon()
+			return zero, err
+		case !isRetryable(err):
+			// The allocator answered, so it is up.
+			c.breaker.success()
+			return zero, err
+		case attempt >= c.config.MaxAttempts:
+			c.failures.Add(1)
+			c.breaker.failure(time.Now())
+			return zero, err
+		}
+
+		c.retries.Add(1)
+		select {
+		case <-ctx.Done():
+			c.breaker.abandon()
+			return zero, fmt.Errorf("%w (retry interrupted: %w)", err, ctx.Err())
+		case <-time.After(c.backoff(attempt)):
+		}
+	}
+}
+
+// backoff returns a random delay of up to InitialBackoff * 2^(attempt-1),
+// capped at MaxBackoff.
+func (c *ResilientClient) backoff(attempt int) time.Duration {
+	ceiling := c.config.MaxBackoff
+	if shift := attempt - 1; shift < 32 {
+		if d := c.config.InitialBackoff << shift; d > 0 && d < ceiling {
+			ceiling = d
+		}
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return rand.N(ceiling) + 1
+}
+
+func isRetryable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted:
+		return true
+	default:
+		return false
+	}
+}
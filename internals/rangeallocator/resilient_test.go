@@ -0,0 +1,132 @@
+package rangeallocator_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/RajNykDhulapkar/gotiny/internals/rangeallocator"
+	"github.com/RajNykDhulapkar/gotiny/internals/rangeallocator/fake"
+)
+
+func TestResilientClientBreaker(t *testing.T) {
+	const cooldown = 100 * time.Millisecond
+
+	server := fake.NewServer(fake.DefaultConfig())
+	conn, err := fake.Dial(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := rangeallocator.NewResilientClient(conn, &rangeallocator.ResilientConfig{
+		CallTimeout:      time.Second,
+		MaxAttempts:      2,
+		InitialBackoff:   time.Millisecond,
+		MaxBackoff:       time.Millisecond,
+		BreakerThreshold: 2,
+		BreakerCooldown:  cooldown,
+	})
+	defer client.Close()
+
+	ctx := context.Background()
+	allocate := func() error {
+		_, err := client.AllocateRange(ctx, "test", nil, nil)
+		return err
+	}
+	expect := func(state rangeallocator.BreakerState) {
+		t.Helper()
+		if got := client.State(); got != state {
+			t.Fatalf("state = %s, want %s", got, state)
+		}
+	}
+
+	if err := allocate(); err != nil {
+		t.Fatal(err)
+	}
+
+	server.SetUnavailable(true)
+	for i := 0; i < 2; i++ {
+		if err := allocate(); err == nil || errors.Is(err, rangeallocator.ErrCircuitOpen) {
+			t.Fatalf("call %d: got %v, want the allocator's error", i, err)
+		}
+	}
+	expect(rangeallocator.BreakerOpen)
+
+	rangesBefore := len(server.Ranges())
+	server.SetUnavailable(false)
+	if err := allocate(); !errors.Is(err, rangeallocator.ErrCircuitOpen) {
+		t.Fatalf("got %v while open, want ErrCircuitOpen", err)
+	}
+	if len(server.Ranges()) != rangesBefore {
+		t.Fatal("a call reached the allocator while the breaker was open")
+	}
+	// Health checks bypass the breaker.
+	if err := client.GetHealth(ctx); err != nil {
+		t.Fatalf("GetHealth while open: %v", err)
+	}
+
+	// A failed probe opens the breaker again.
+	time.Sleep(cooldown)
+	server.SetUnavailable(true)
+	if err := allocate(); err == nil || errors.Is(err, rangeallocator.ErrCircuitOpen) {
+		t.Fatalf("probe: got %v, want the allocator's error", err)
+	}
+	expect(rangeallocator.BreakerOpen)
+
+	// A successful probe closes it.
+	time.Sleep(cooldown)
+	server.SetUnavailable(false)
+	if err := allocate(); err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	expect(rangeallocator.BreakerClosed)
+
+	stats := client.Stats()
+	if stats.Failures != 3 || stats.Rejected != 1 || stats.Retries != 3 {
+		t.Errorf("stats = %+v, want 3 failures, 1 rejected call and 3 retries", stats)
+	}
+}
+
+func TestRangeManagerSkipsPrefetchWhileOpen(t *testing.T) {
+	server := fake.NewServer(fake.DefaultConfig())
+	conn, err := fake.Dial(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := rangeallocator.NewResilientClient(conn, &rangeallocator.ResilientConfig{
+		CallTimeout:      time.Second,
+		MaxAttempts:      1,
+		BreakerThreshold: 1,
+		BreakerCooldown:  time.Hour,
+	})
+	defer client.Close()
+
+	manager := rangeallocator.NewRangeManager(client, &rangeallocator.RangeManagerConfig{
+		ServiceID:         "test",
+		RangeSize:         10,
+		PrefetchThreshold: 0.5,
+	})
+	if _, err := manager.GetNextID(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	server.SetUnavailable(true)
+	if _, err := client.GetRange(context.Background(), "fake-1"); err == nil {
+		t.Fatal("GetRange succeeded while unavailable")
+	}
+	if got := manager.Stats().AllocatorState; got != rangeallocator.BreakerOpen {
+		t.Fatalf("AllocatorState = %s, want open", got)
+	}
+
+	for i := 0; i < 10; i++ {
+		if _, err := manager.GetNextID(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if stats := manager.Stats(); stats.Prefetches != 0 || stats.PrefetchFailures != 0 {
+		t.Errorf("prefetched while the breaker was open: %+v", stats)
+	}
+	if rejected := client.Stats().Rejected; rejected != 0 {
+		t.Errorf("%d calls were rejected by the breaker", rejected)
+	}
+}
@@ -0,0 +1,83 @@
+package rangeallocator
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// rateSmoothing weights the latest consumption rate sample against the
+// running average.
+const rateSmoothing = 0.5
+
+// rangeSizer picks range sizes so that a range lasts about the target refill
+// interval at the observed ID consumption rate.
+type rangeSizer struct {
+	minSize int64
+	maxSize int64
+	target  time.Duration
+
+	mu          sync.Mutex
+	rate        float64
+	lastSample  time.Time
+	lastIssued  int64
+	hasEstimate bool
+}
+
+func newRangeSizer(config *RangeManagerConfig) *rangeSizer {
+	return &rangeSizer{
+		minSize: config.MinRangeSize,
+		maxSize: config.MaxRangeSize,
+		target:  config.TargetRefillInterval,
+	}
+}
+
+// nextSize samples the number of IDs issued so far and returns the size of
+// the next range to request, falling back to defaultSize until a rate is
+// known or when adaptive sizing is disabled.
+func (s *rangeSizer) nextSize(issued, defaultSize int64, now time.Time) int64 {
+	if s.target <= 0 {
+		return defaultSize
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.lastSample.IsZero() {
+		if elapsed := now.Sub(s.lastSample).Seconds(); elapsed > 0 {
+			sample := float64(issued-s.lastIssued) / elapsed
+			if s.hasEstimate {
+				s.rate = rateSmoothing*sample + (1-rateSmoothing)*s.rate
+			} else {
+				s.rate = sample
+				s.hasEstimate = true
+			}
+		}
+	}
+	s.lastSample = now
+	s.lastIssued = issued
+
+	size := defaultSize
+	if s.hasEstimate {
+		size = int64(math.Ceil(s.rate * s.target.Seconds()))
+	}
+	return s.clamp(size)
+}
+
+func (s *rangeSizer) clamp(size int64) int64 {
+	if s.minSize > 0 && size < s.minSize {
+		size = s.minSize
+	}
+	if s.maxSize > 0 && size > s.maxSize {
+		size = s.maxSize
+	}
+	// The allocator rejects empty ranges, e.g. for an idle replica without a
+	// minimum size.
+	return max(size, 1)
+}
+
+func (s *rangeSizer) currentRate() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rate
+}
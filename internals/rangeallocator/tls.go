@@ -0,0 +1,209 @@
+package rangeallocator
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/credentials"
+)
+
+type TLSConfig struct {
+	// CAFile is a PEM bundle of CAs trusted to sign the allocator's
+	// certificate. Empty means the system roots.
+	CAFile string `yaml:"caFile"`
+	// CertFile and KeyFile are the client certificate presented for mTLS.
+	CertFile string `yaml:"certFile"`
+	KeyFile  string `yaml:"keyFile"`
+	// ServerName overrides the name the allocator's certificate is checked
+	// against, which defaults to the host in Address.
+	ServerName string `yaml:"serverName"`
+	// ReloadInterval is how often the files are checked for changes, so
+	// rotated certificates are picked up without a restart. Zero disables
+	// reloading.
+	ReloadInterval time.Duration `yaml:"reloadInterval"`
+}
+
+// tlsReloader holds the current CA pool and client certificate and re-reads
+// them from disk when they change. New handshakes use the new files;
+// established connections are unaffected.
+type tlsReloader struct {
+	config *TLSConfig
+
+	mu        sync.RWMutex
+	roots     *x509.CertPool
+	cert      *tls.Certificate
+	modTimes  [3]time.Time
+	checkedAt time.Time
+}
+
+func newTLSReloader(config *TLSConfig) (*tlsReloader, error) {
+	if (config.CertFile == "") != (config.KeyFile == "") {
+		return nil, fmt.Errorf("range allocator TLS: cert file and key file must be set together")
+	}
+
+	r := &tlsReloader{config: config}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// tlsConfig returns a client tls.Config with the current CA pool and client
+// certificate. An empty serverName is filled in with the dialed host by
+// gRPC, so the allocator's certificate is always checked against the name or
+// IP address it was reached by.
+func (r *tlsReloader) tlsConfig(serverName string) *tls.Config {
+	r.maybeReload()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: serverName,
+		RootCAs:    r.roots,
+	}
+	if r.cert != nil {
+		cfg.Certificates = []tls.Certificate{*r.cert}
+	}
+	return cfg
+}
+
+// reloadingCredentials are gRPC transport credentials that build a new
+// tls.Config for every handshake, so new connections use the current files
+// while Go's standard certificate verification stays in place.
+type reloadingCredentials struct {
+	reloader   *tlsReloader
+	serverName string
+}
+
+func newReloadingCredentials(config *TLSConfig) (*reloadingCredentials, error) {
+	reloader, err := newTLSReloader(config)
+	if err != nil {
+		return nil, err
+	}
+	return &reloadingCredentials{reloader: reloader, serverName: config.ServerName}, nil
+}
+
+func (c *reloadingCredentials) ClientHandshake(ctx context.Context, authority string, rawConn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return credentials.NewTLS(c.reloader.tlsConfig(c.serverName)).ClientHandshake(ctx, authority, rawConn)
+}
+
+func (c *reloadingCredentials) ServerHandshake(net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return nil, nil, errors.New("range allocator TLS credentials are client only")
+}
+
+func (c *reloadingCredentials) Info() credentials.ProtocolInfo {
+	return credentials.ProtocolInfo{
+		SecurityProtocol: "tls",
+		SecurityVersion:  "1.2",
+		ServerName:       c.serverName,
+	}
+}
+
+func (c *reloadingCredentials) Clone() credentials.TransportCredentials {
+	clone := *c
+	return &clone
+}
+
+func (c *reloadingCredentials) OverrideServerName(serverName string) error {
+	c.serverName = serverName
+	return nil
+}
+
+func (r *tlsReloader) maybeReload() {
+	if r.config.ReloadInterval <= 0 {
+		return
+	}
+
+	r.mu.RLock()
+	due := time.Since(r.checkedAt) >= r.config.ReloadInterval
+	r.mu.RUnlock()
+	if !due {
+		return
+	}
+
+	// A half-written or invalid file keeps the previous certificates in use.
+	if err := r.load(); err != nil {
+		log.Printf("Failed to reload range allocator TLS files: %v", err)
+		r.mu.Lock()
+		r.checkedAt = time.Now()
+		r.mu.Unlock()
+	}
+}
+
+// load reads the CA bundle and client certificate if any of the files
+// changed since the last load.
+func (r *tlsReloader) load() error {
+	var modTimes [3]time.Time
+	for i, path := range []string{r.config.CAFile, r.config.CertFile, r.config.KeyFile} {
+		if path == "" {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("range allocator TLS: %w", err)
+		}
+		modTimes[i] = info.ModTime()
+	}
+
+	r.mu.RLock()
+	unchanged := !r.checkedAt.IsZero() && modTimes == r.modTimes
+	r.mu.RUnlock()
+	if unchanged {
+		r.mu.Lock()
+		r.checkedAt = time.Now()
+		r.mu.Unlock()
+		return nil
+	}
+
+	roots, err := loadRoots(r.config.CAFile)
+	if err != nil {
+		return err
+	}
+
+	var cert *tls.Certificate
+	if r.config.CertFile != "" {
+		pair, err := tls.LoadX509KeyPair(r.config.CertFile, r.config.KeyFile)
+		if err != nil {
+			return fmt.Errorf("range allocator TLS: failed to load client certificate: %w", err)
+		}
+		cert = &pair
+	}
+
+	r.mu.Lock()
+	r.roots = roots
+	r.cert = cert
+	r.modTimes = modTimes
+	r.checkedAt = time.Now()
+	r.mu.Unlock()
+	return nil
+}
+
+func loadRoots(caFile string) (*x509.CertPool, error) {
+	if caFile == "" {
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			return nil, fmt.Errorf("range allocator TLS: failed to load system roots: %w", err)
+		}
+		return roots, nil
+	}
+
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("range allocator TLS: %w", err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("range allocator TLS: no certificates found in %s", caFile)
+	}
+	return roots, nil
+}
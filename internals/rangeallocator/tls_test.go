@@ -0,0 +1,249 @@
+package rangeallocator_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/RajNykDhulapkar/gotiny-range-allocator/pkg/pb"
+	"github.com/RajNykDhulapkar/gotiny/internals/rangeallocator"
+	"github.com/RajNykDhulapkar/gotiny/internals/rangeallocator/fake"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns a PEM certificate and key signed by the CA for the given
+// DNS names and IP addresses.
+func (ca *testCA) issue(t *testing.T, usage x509.ExtKeyUsage, dnsNames []string, ips ...net.IP) (certPEM, keyPEM []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "gotiny test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		DNSNames:     dnsNames,
+		IPAddresses:  ips,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func writeFile(t *testing.T, dir, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// serveTLS starts a fake allocator on 127.0.0.1 that presents the given
+// certificate and requires a client certificate signed by clientCA.
+func serveTLS(t *testing.T, certPEM, keyPEM []byte, clientCA *testCA) string {
+	t.Helper()
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCA.cert)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer(grpc.Creds(credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    clientCAs,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	})))
+	pb.RegisterRangeAllocatorServer(server, fake.NewServer(fake.DefaultConfig()))
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	return listener.Addr().String()
+}
+
+func dialTLS(t *testing.T, address string, config *rangeallocator.TLSConfig) error {
+	t.Helper()
+
+	client, err := rangeallocator.NewClient(&rangeallocator.ClientConfig{
+		Address:     address,
+		DialTimeout: time.Second,
+		TLS:         config,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	return client.GetHealth(ctx)
+}
+
+func TestClientTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, "gotiny test CA")
+	caFile := writeFile(t, dir, "ca.crt", ca.pem)
+
+	clientCert, clientKey := ca.issue(t, x509.ExtKeyUsageClientAuth, nil)
+	certFile := writeFile(t, dir, "client.crt", clientCert)
+	keyFile := writeFile(t, dir, "client.key", clientKey)
+
+	serverCert, serverKey := ca.issue(t, x509.ExtKeyUsageServerAuth, []string{"range-allocator"}, net.IPv4(127, 0, 0, 1))
+	address := serveTLS(t, serverCert, serverKey, ca)
+
+	// A certificate that is valid, but for another name and without the IP.
+	evilCert, evilKey := ca.issue(t, x509.ExtKeyUsageServerAuth, []string{"evil.example"})
+	evilAddress := serveTLS(t, evilCert, evilKey, ca)
+
+	mtls := rangeallocator.TLSConfig{CAFile: caFile, CertFile: certFile, KeyFile: keyFile}
+	withName := func(config rangeallocator.TLSConfig, name string) *rangeallocator.TLSConfig {
+		config.ServerName = name
+		return &config
+	}
+
+	tests := []struct {
+		name    string
+		address string
+		config  *rangeallocator.TLSConfig
+		wantErr bool
+	}{
+		{"mTLS by IP", address, &mtls, false},
+		{"mTLS with server name", address, withName(mtls, "range-allocator"), false},
+		{"no client certificate", address, &rangeallocator.TLSConfig{CAFile: caFile}, true},
+		{"wrong server name", address, withName(mtls, "other.example"), true},
+		{"IP not in certificate", evilAddress, &mtls, true},
+		{"name of another certificate", evilAddress, withName(mtls, "range-allocator"), true},
+		{"matching name of another certificate", evilAddress, withName(mtls, "evil.example"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := dialTLS(t, tt.address, tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got %v, want error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClientTLSReload(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, "gotiny test CA")
+	otherCA := newTestCA(t, "other CA")
+
+	clientCert, clientKey := ca.issue(t, x509.ExtKeyUsageClientAuth, nil)
+	serverCert, serverKey := ca.issue(t, x509.ExtKeyUsageServerAuth, nil, net.IPv4(127, 0, 0, 1))
+	address := serveTLS(t, serverCert, serverKey, ca)
+
+	// The client starts out trusting the wrong CA.
+	caFile := writeFile(t, dir, "ca.crt", otherCA.pem)
+	client, err := rangeallocator.NewClient(&rangeallocator.ClientConfig{
+		Address:     address,
+		DialTimeout: time.Second,
+		TLS: &rangeallocator.TLSConfig{
+			CAFile:         caFile,
+			CertFile:       writeFile(t, dir, "client.crt", clientCert),
+			KeyFile:        writeFile(t, dir, "client.key", clientKey),
+			ReloadInterval: 10 * time.Millisecond,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	err = client.GetHealth(ctx)
+	cancel()
+	if err == nil {
+		t.Fatal("connected while trusting the wrong CA")
+	}
+
+	writeFile(t, dir, "ca.crt", ca.pem)
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(caFile, later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	// The connection reconnects with backoff, so allow a few attempts.
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		err = client.GetHealth(ctx)
+		cancel()
+		if err == nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("still failing after the CA file was replaced: %v", err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
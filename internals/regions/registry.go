@@ -0,0 +1,94 @@
+package regions
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/RajNykDhulapkar/gotiny/internals/shortener"
+)
+
+const DefaultRegion = "default"
+
+var ErrInvalidPrefixes = errors.New("invalid region prefixes")
+
+// Registry knows the region this replica runs in and, optionally, the
+// one-character prefix each region puts on the codes it generates, so any
+// replica can tell which region's datastore owns a generated code.
+type Registry struct {
+	local    string
+	alphabet string
+	prefixes map[string]string
+	owners   map[byte]string
+}
+
+// NewRegistry builds a registry for the local region. prefixes maps region
+// names to distinct single characters from alphabet; it may be empty, in
+// which case codes carry no region.
+func NewRegistry(local string, prefixes map[string]string, alphabet string) (*Registry, error) {
+	if local == "" {
+		local = DefaultRegion
+	}
+
+	r := &Registry{
+		local:    local,
+		alphabet: alphabet,
+		prefixes: make(map[string]string, len(prefixes)),
+		owners:   make(map[byte]string, len(prefixes)),
+	}
+	for region, prefix := range prefixes {
+		if len(prefix) != 1 || !strings.Contains(alphabet, prefix) {
+			return nil, fmt.Errorf("%w: prefix %q for region %s must be a single code character", ErrInvalidPrefixes, prefix, region)
+		}
+		if other, taken := r.owners[prefix[0]]; taken {
+			return nil, fmt.Errorf("%w: regions %s and %s share prefix %q", ErrInvalidPrefixes, other, region, prefix)
+		}
+		r.prefixes[region] = prefix
+		r.owners[prefix[0]] = region
+	}
+	if len(r.prefixes) > 0 && r.prefixes[local] == "" {
+		return nil, fmt.Errorf("%w: no prefix for the local region %s", ErrInvalidPrefixes, local)
+	}
+
+	return r, nil
+}
+
+// ParsePrefixes parses "region=prefix" pairs separated by commas, e.g.
+// "us-east=1,eu-west=2".
+func ParsePrefixes(spec string) (map[string]string, error) {
+	prefixes := make(map[string]string)
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		region, prefix, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(region) == "" {
+			return nil, fmt.Errorf("%w: %q is not region=prefix", ErrInvalidPrefixes, pair)
+		}
+		prefixes[strings.TrimSpace(region)] = strings.TrimSpace(prefix)
+	}
+	return prefixes, nil
+}
+
+func (r *Registry) Local() string {
+	return r.local
+}
+
+// Prefix is the prefix for codes generated in the local region, or "" when
+// codes carry no region.
+func (r *Registry) Prefix() string {
+	return r.prefixes[r.local]
+}
+
+// OwnerOf returns the region whose prefix code starts with. Only sequential
+// codes carry a region; random and hash codes, aliases and codes from before
+// prefixes were configured end in a different check character and have no
+// owner, save for the odd mistyped code.
+func (r *Registry) OwnerOf(code string) (string, bool) {
+	if !shortener.IsRegionalCode(r.alphabet, code) {
+		return "", false
+	}
+	region, ok := r.owners[code[0]]
+	return region, ok
+}
@@ -0,0 +1,77 @@
+package regions
+
+import (
+	"context"
+	"testing"
+
+	"github.com/RajNykDhulapkar/gotiny-range-allocator/pkg/pb"
+	"github.com/RajNykDhulapkar/gotiny/internals/shortener"
+)
+
+const alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+type fixedID int64
+
+func (id fixedID) GetNextID(context.Context) (int64, error) { return int64(id), nil }
+
+func (id fixedID) GetCurrentRange() *pb.Range { return nil }
+
+func generate(t *testing.T, prefix string, id int64) string {
+	t.Helper()
+	code, err := shortener.NewShortener(fixedID(id), &shortener.Config{RegionPrefix: prefix}).
+		GenerateShortLink(context.Background(), "", "https://example.com", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+func TestOwnerOf(t *testing.T) {
+	r, err := NewRegistry("us-east", map[string]string{"us-east": "1", "eu-west": "2"}, alphabet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		code  string
+		owner string
+	}{
+		{generate(t, "1", 5), "us-east"},
+		{generate(t, "2", 123456), "eu-west"},
+		// An unprefixed code for ID 67 encodes to the same "15" body as
+		// prefix "1" with ID 5, but ends in the plain check character.
+		{generate(t, "", 67), ""},
+		{generate(t, "", 2000000), ""},
+		{"2-for-1", ""},
+		{"2023SummerSale", ""},
+	}
+
+	for _, tt := range tests {
+		owner, ok := r.OwnerOf(tt.code)
+		if ok != (tt.owner != "") || owner != tt.owner {
+			t.Errorf("OwnerOf(%q) = %q, %v; want %q", tt.code, owner, ok, tt.owner)
+		}
+	}
+}
+
+func TestNewRegistry(t *testing.T) {
+	tests := []struct {
+		name     string
+		local    string
+		prefixes map[string]string
+		wantErr  bool
+	}{
+		{"no prefixes", "us-east", nil, false},
+		{"local prefix", "us-east", map[string]string{"us-east": "1"}, false},
+		{"missing local prefix", "us-east", map[string]string{"eu-west": "2"}, true},
+		{"shared prefix", "us-east", map[string]string{"us-east": "1", "eu-west": "1"}, true},
+		{"prefix outside the alphabet", "us-east", map[string]string{"us-east": "-"}, true},
+		{"long prefix", "us-east", map[string]string{"us-east": "12"}, true},
+	}
+
+	for _, tt := range tests {
+		if _, err := NewRegistry(tt.local, tt.prefixes, alphabet); (err != nil) != tt.wantErr {
+			t.Errorf("%s: got %v, want error: %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
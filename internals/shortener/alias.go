@@ -0,0 +1,62 @@
+package shortener
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	MinAliasLength = 3
+	MaxAliasLength = 64
+
+	// maxGeneratedLength is the longest code a strategy can produce: an int64
+	// encodes to at most 13 characters over the smallest allowed alphabet,
+	// plus the check character.
+	maxGeneratedLength = 14
+
+	// legacyAliasMinLength is the shortest purely alphanumeric alias that was
+	// allowed before maxGeneratedLength was raised to fit custom alphabets.
+	legacyAliasMinLength = 13
+)
+
+var ErrInvalidAlias = errors.New("invalid alias")
+
+// ValidateAlias checks that alias only uses letters, digits, '-' and '_', and
+// that it can never be produced by GenerateShortLink. Generated codes are
+// purely alphanumeric and at most maxGeneratedLength long, so an alias must
+// either contain '-' or '_' or be longer than that.
+func ValidateAlias(alias string) error {
+	if len(alias) < MinAliasLength || len(alias) > MaxAliasLength {
+		return fmt.Errorf("%w: length must be between %d and %d", ErrInvalidAlias, MinAliasLength, MaxAliasLength)
+	}
+
+	for i := 0; i < len(alias); i++ {
+		c := alias[i]
+		if c != '-' && c != '_' && strings.IndexByte(charset, c) == -1 {
+			return fmt.Errorf("%w: invalid character %q", ErrInvalidAlias, c)
+		}
+	}
+
+	if !strings.ContainsAny(alias, "-_") && len(alias) <= maxGeneratedLength {
+		return fmt.Errorf("%w: aliases of %d characters or fewer must contain '-' or '_'", ErrInvalidAlias, maxGeneratedLength)
+	}
+
+	return nil
+}
+
+// isLegacyAlias reports whether code is a purely alphanumeric alias that was
+// allowed before maxGeneratedLength was raised. ValidateAlias rejects new
+// ones, but existing ones must keep resolving even though they look like
+// generated codes without a valid check character.
+func isLegacyAlias(code string) bool {
+	if len(code) < legacyAliasMinLength || len(code) > maxGeneratedLength {
+		return false
+	}
+	for i := 0; i < len(code); i++ {
+		if strings.IndexByte(charset, code[i]) == -1 {
+			return false
+		}
+	}
+	return true
+}
@@ -3,29 +3,110 @@ package shortener
 import (
 	"errors"
 	"fmt"
+	"math"
+	"strings"
 )
 
 var (
-	ErrInvalidInput = errors.New("invalid input for encoding")
-	charset         = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
-	base            = int64(len(charset))
+	ErrInvalidInput    = errors.New("invalid input for encoding")
+	ErrEmptyInput      = errors.New("empty input for decoding")
+	ErrNegativeInput   = errors.New("negative numbers cannot be encoded")
+	ErrOverflow        = errors.New("decoded value overflows int64")
+	ErrInvalidAlphabet = errors.New("invalid alphabet")
+	charset            = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	base               = int64(len(charset))
 )
 
-type Base62Encoder struct{}
+// MinAlphabetLength keeps generated codes short enough to stay disjoint from
+// custom aliases; see maxGeneratedLength.
+const MinAlphabetLength = 32
+
+type Base62Encoder struct {
+	alphabet  string
+	base      int64
+	minLength int
+	// digits maps each byte to its value in alphabet, or -1.
+	digits [256]int8
+}
 
 func NewBase62Encoder() *Base62Encoder {
-	return &Base62Encoder{}
+	return newEncoder(charset, 0)
+}
+
+func newEncoder(alphabet string, minLength int) *Base62Encoder {
+	b := &Base62Encoder{
+		alphabet:  alphabet,
+		base:      int64(len(alphabet)),
+		minLength: minLength,
+	}
+
+	for i := range b.digits {
+		b.digits[i] = -1
+	}
+	for i := 0; i < len(alphabet); i++ {
+		b.digits[alphabet[i]] = int8(i)
+	}
+	return b
 }
 
-func (b *Base62Encoder) Encode(num int64) string {
+// NewEncoder creates an encoder over a custom alphabet, e.g. one without the
+// look-alike characters 0/O/1/l/I for printed material. Codes shorter than
+// minLength are left-padded with the alphabet's zero digit; combined with a
+// bounded ID space this yields fixed-length codes.
+func NewEncoder(alphabet string, minLength int) (*Base62Encoder, error) {
+	if err := validateAlphabet(alphabet); err != nil {
+		return nil, err
+	}
+	if minLength < 0 || minLength >= maxGeneratedLength {
+		return nil, fmt.Errorf("minimum length must be between 0 and %d", maxGeneratedLength-1)
+	}
+
+	return newEncoder(alphabet, minLength), nil
+}
+
+// validateAlphabet requires at least MinAlphabetLength distinct characters
+// from the base62 charset, so codes never contain characters reserved for
+// aliases.
+func validateAlphabet(alphabet string) error {
+	if len(alphabet) < MinAlphabetLength {
+		return fmt.Errorf("%w: needs at least %d characters", ErrInvalidAlphabet, MinAlphabetLength)
+	}
+
+	for i := 0; i < len(alphabet); i++ {
+		c := alphabet[i]
+		if strings.IndexByte(charset, c) == -1 {
+			return fmt.Errorf("%w: character %q is not alphanumeric", ErrInvalidAlphabet, c)
+		}
+		if strings.IndexByte(alphabet[:i], c) != -1 {
+			return fmt.Errorf("%w: duplicate character %q", ErrInvalidAlphabet, c)
+		}
+	}
+	return nil
+}
+
+func (b *Base62Encoder) Alphabet() string {
+	return b.alphabet
+}
+
+// Encode returns the code for num. Negative numbers are rejected with
+// ErrNegativeInput.
+func (b *Base62Encoder) Encode(num int64) (string, error) {
+	if num < 0 {
+		return "", fmt.Errorf("%w: %d", ErrNegativeInput, num)
+	}
+
+	encoded := make([]byte, 0, b.minLength)
 	if num == 0 {
-		return string(charset[0])
+		encoded = append(encoded, b.alphabet[0])
 	}
 
-	encoded := make([]byte, 0)
 	for num > 0 {
-		encoded = append(encoded, charset[num%base])
-		num = num / base
+		encoded = append(encoded, b.alphabet[num%b.base])
+		num = num / b.base
+	}
+
+	for len(encoded) < b.minLength {
+		encoded = append(encoded, b.alphabet[0])
 	}
 
 	// Reverse the slice
@@ -33,27 +114,28 @@ func (b *Base62Encoder) Encode(num int64) string {
 		encoded[i], encoded[j] = encoded[j], encoded[i]
 	}
 
-	return string(encoded)
+	return string(encoded), nil
 }
 
+// Decode returns the number encoded by a code. It fails with ErrEmptyInput,
+// ErrInvalidInput for characters outside the alphabet, or ErrOverflow when
+// the value does not fit in an int64.
 func (b *Base62Encoder) Decode(encoded string) (int64, error) {
+	if encoded == "" {
+		return 0, ErrEmptyInput
+	}
+
 	var num int64
 	for i := 0; i < len(encoded); i++ {
-		char := encoded[i]
-		pos := int64(-1)
-
-		for j, c := range charset {
-			if char == byte(c) {
-				pos = int64(j)
-				break
-			}
-		}
-
+		pos := int64(b.digits[encoded[i]])
 		if pos == -1 {
-			return 0, fmt.Errorf("%w: invalid character %c", ErrInvalidInput, char)
+			return 0, fmt.Errorf("%w: invalid character %c", ErrInvalidInput, encoded[i])
 		}
 
-		num = num*base + pos
+		if num > (math.MaxInt64-pos)/b.base {
+			return 0, fmt.Errorf("%w: %s", ErrOverflow, encoded)
+		}
+		num = num*b.base + pos
 	}
 	return num, nil
 }
@@ -0,0 +1,144 @@
+package shortener
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	encoder := NewBase62Encoder()
+
+	tests := []struct {
+		num  int64
+		code string
+	}{
+		{0, "0"},
+		{1, "1"},
+		{61, "z"},
+		{62, "10"},
+		{1000, "G8"},
+		{math.MaxInt64, "AzL8n0Y58m7"},
+	}
+
+	for _, tt := range tests {
+		code, err := encoder.Encode(tt.num)
+		if err != nil {
+			t.Fatalf("Encode(%d): %v", tt.num, err)
+		}
+		if code != tt.code {
+			t.Errorf("Encode(%d) = %q, want %q", tt.num, code, tt.code)
+		}
+
+		num, err := encoder.Decode(tt.code)
+		if err != nil {
+			t.Fatalf("Decode(%q): %v", tt.code, err)
+		}
+		if num != tt.num {
+			t.Errorf("Decode(%q) = %d, want %d", tt.code, num, tt.num)
+		}
+	}
+}
+
+func TestEncodeNegative(t *testing.T) {
+	for _, num := range []int64{-1, math.MinInt64} {
+		if _, err := NewBase62Encoder().Encode(num); !errors.Is(err, ErrNegativeInput) {
+			t.Errorf("Encode(%d): got %v, want ErrNegativeInput", num, err)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		code string
+		want error
+	}{
+		{"", ErrEmptyInput},
+		{"ab-c", ErrInvalidInput},
+		{"é", ErrInvalidInput},
+		// One more than math.MaxInt64.
+		{"AzL8n0Y58m8", ErrOverflow},
+		{"zzzzzzzzzzzz", ErrOverflow},
+	}
+
+	for _, tt := range tests {
+		if _, err := NewBase62Encoder().Decode(tt.code); !errors.Is(err, tt.want) {
+			t.Errorf("Decode(%q): got %v, want %v", tt.code, err, tt.want)
+		}
+	}
+}
+
+func TestEncoderMinLength(t *testing.T) {
+	encoder, err := NewEncoder("23456789ABCDEFGHJKLMNPQRSTUVWXYZ", 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, err := encoder.Encode(33)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != "222233" {
+		t.Fatalf("Encode(33) = %q, want %q", code, "222233")
+	}
+	if num, err := encoder.Decode(code); err != nil || num != 33 {
+		t.Fatalf("Decode(%q) = %d, %v; want 33", code, num, err)
+	}
+}
+
+func FuzzEncodeDecode(f *testing.F) {
+	for _, num := range []int64{0, 1, 61, 62, 1000, math.MaxInt64} {
+		f.Add(num)
+	}
+
+	encoders := []*Base62Encoder{NewBase62Encoder()}
+	if encoder, err := NewEncoder("23456789ABCDEFGHJKLMNPQRSTUVWXYZ", 8); err == nil {
+		encoders = append(encoders, encoder)
+	}
+
+	f.Fuzz(func(t *testing.T, num int64) {
+		for _, encoder := range encoders {
+			code, err := encoder.Encode(num)
+			if num < 0 {
+				if !errors.Is(err, ErrNegativeInput) {
+					t.Fatalf("Encode(%d): got %v, want ErrNegativeInput", num, err)
+				}
+				continue
+			}
+			if err != nil {
+				t.Fatalf("Encode(%d): %v", num, err)
+			}
+			if len(code)+1 > maxGeneratedLength {
+				t.Fatalf("Encode(%d) = %q leaves no room for the check character", num, code)
+			}
+
+			decoded, err := encoder.Decode(code)
+			if err != nil {
+				t.Fatalf("Decode(%q): %v", code, err)
+			}
+			if decoded != num {
+				t.Fatalf("Decode(Encode(%d)) = %d", num, decoded)
+			}
+		}
+	})
+}
+
+func BenchmarkEncode(b *testing.B) {
+	encoder := NewBase62Encoder()
+	for i := 0; i < b.N; i++ {
+		_, _ = encoder.Encode(int64(i) * 7919)
+	}
+}
+
+func BenchmarkDecode(b *testing.B) {
+	encoder := NewBase62Encoder()
+	code, err := encoder.Encode(math.MaxInt64)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = encoder.Decode(code)
+	}
+}
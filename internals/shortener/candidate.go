@@ -0,0 +1,93 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/RajNykDhulapkar/gotiny/internals/blocklist"
+	"github.com/RajNykDhulapkar/gotiny/pkg/interfaces"
+)
+
+var ErrNoFreeCode = errors.New("no free short code found")
+
+// CandidateConfig configures the shorteners that try candidate codes until
+// one is free.
+type CandidateConfig struct {
+	// Length is the total code length including the check character.
+	Length      int
+	MaxAttempts int
+	// Alphabet defaults to the base62 charset.
+	Alphabet string
+	// Blocklist rejects candidates that spell reserved or offensive words.
+	Blocklist *blocklist.Blocklist
+}
+
+func DefaultCandidateConfig() *CandidateConfig {
+	return &CandidateConfig{
+		Length:      8,
+		MaxAttempts: 5,
+		Alphabet:    charset,
+	}
+}
+
+// candidateFunc returns the number behind the code tried in the given
+// attempt.
+type candidateFunc func(domain, originalURL, userID string, attempt int) (uint64, error)
+
+// candidateShortener turns candidates into fixed-length codes and returns
+// the first one that is neither blocked nor taken.
+type candidateShortener struct {
+	repository interfaces.URLRepository
+	config     *CandidateConfig
+	candidate  candidateFunc
+}
+
+func newCandidateShortener(name string, repository interfaces.URLRepository, config *CandidateConfig, candidate candidateFunc) (*candidateShortener, error) {
+	if config == nil {
+		config = DefaultCandidateConfig()
+	}
+	if config.Length < 2 || config.Length > maxGeneratedLength {
+		return nil, fmt.Errorf("%s code length must be between 2 and %d", name, maxGeneratedLength)
+	}
+	if config.Alphabet == "" {
+		config.Alphabet = charset
+	}
+	if err := validateAlphabet(config.Alphabet); err != nil {
+		return nil, err
+	}
+
+	return &candidateShortener{
+		repository: repository,
+		config:     config,
+		candidate:  candidate,
+	}, nil
+}
+
+func (s *candidateShortener) GenerateShortLink(ctx context.Context, domain, originalURL, userID string) (string, error) {
+	for attempt := 0; attempt < s.config.MaxAttempts; attempt++ {
+		n, err := s.candidate(domain, originalURL, userID, attempt)
+		if err != nil {
+			return "", err
+		}
+
+		code := fixedLengthCode(s.config.Alphabet, n, s.config.Length)
+		if s.config.Blocklist.IsBlocked(code) {
+			continue
+		}
+
+		existing, err := s.repository.FindByShortURL(ctx, domain, code)
+		if err != nil {
+			return "", err
+		}
+		if existing == nil {
+			return code, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w after %d attempts", ErrNoFreeCode, s.config.MaxAttempts)
+}
+
+func (s *candidateShortener) ValidateShortLink(code string) error {
+	return validateCode(s.config.Alphabet, code)
+}
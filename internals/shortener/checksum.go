@@ -0,0 +1,84 @@
+package shortener
+
+import (
+	"errors"
+	"strings"
+)
+
+var ErrInvalidShortLink = errors.New("invalid short link")
+
+// checkChar computes a Luhn mod N check character over a code written in
+// alphabet. It catches every single-character typo and most adjacent
+// transpositions.
+func checkChar(alphabet, code string) byte {
+	n := int64(len(alphabet))
+	factor := int64(2)
+	sum := int64(0)
+
+	for i := len(code) - 1; i >= 0; i-- {
+		addend := factor * int64(strings.IndexByte(alphabet, code[i]))
+		factor = 3 - factor
+		sum += addend/n + addend%n
+	}
+
+	return alphabet[(n-sum%n)%n]
+}
+
+// regionalCheckChar is the check character of codes that carry a region
+// prefix: the character after checkChar in alphabet. A prefixed code thus
+// never equals an unprefixed code with an ID-based check character, e.g.
+// prefix "1" and ID 5 against ID 67, which both encode to "15". Older codes
+// with URL-based check characters are kept apart in Shortener.regionalBody.
+func regionalCheckChar(alphabet, code string) byte {
+	i := strings.IndexByte(alphabet, checkChar(alphabet, code))
+	return alphabet[(i+1)%len(alphabet)]
+}
+
+// IsRegionalCode reports whether code has the shape and check character of a
+// code generated over alphabet with a region prefix.
+func IsRegionalCode(alphabet, code string) bool {
+	return len(code) >= 3 && isGeneratedShape(alphabet, code) &&
+		regionalCheckChar(alphabet, code[:len(code)-1]) == code[len(code)-1]
+}
+
+// isGeneratedShape reports whether code has the shape of a code generated
+// over alphabet rather than a custom alias; see ValidateAlias.
+func isGeneratedShape(alphabet, code string) bool {
+	if len(code) < 2 || len(code) > maxGeneratedLength {
+		return false
+	}
+	for i := 0; i < len(code); i++ {
+		if strings.IndexByte(alphabet, code[i]) == -1 {
+			return false
+		}
+	}
+	return true
+}
+
+// validateCode accepts well-formed aliases and codes generated over alphabet
+// with a matching check character.
+func validateCode(alphabet, code string) error {
+	if isGeneratedShape(alphabet, code) {
+		if checkChar(alphabet, code[:len(code)-1]) == code[len(code)-1] {
+			return nil
+		}
+	} else if ValidateAlias(code) == nil {
+		return nil
+	}
+
+	if isLegacyAlias(code) {
+		return nil
+	}
+	return ErrInvalidShortLink
+}
+
+// fixedLengthCode encodes n over alphabet into a code of exactly length
+// characters, the last of which is the check character.
+func fixedLengthCode(alphabet string, n uint64, length int) string {
+	body := make([]byte, length-1)
+	for i := len(body) - 1; i >= 0; i-- {
+		body[i] = alphabet[n%uint64(len(alphabet))]
+		n /= uint64(len(alphabet))
+	}
+	return string(body) + string(checkChar(alphabet, string(body)))
+}
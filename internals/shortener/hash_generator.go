@@ -0,0 +1,29 @@
+package shortener
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/RajNykDhulapkar/gotiny/pkg/interfaces"
+)
+
+// HashShortener derives codes from a hash of the destination, so the same
+// user shortening the same URL on the same domain gets the same first
+// candidate. Taken candidates are skipped by rehashing with a counter.
+type HashShortener struct {
+	*candidateShortener
+}
+
+func NewHashShortener(repository interfaces.URLRepository, config *CandidateConfig) (*HashShortener, error) {
+	s, err := newCandidateShortener("hash", repository, config, hashCandidate)
+	if err != nil {
+		return nil, err
+	}
+	return &HashShortener{s}, nil
+}
+
+func hashCandidate(domain, originalURL, userID string, attempt int) (uint64, error) {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%s\x00%s\x00%d", domain, userID, originalURL, attempt)))
+	return binary.BigEndian.Uint64(sum[:8]), nil
+}
@@ -0,0 +1,92 @@
+package shortener
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const feistelRounds = 4
+
+var (
+	ErrInvalidObfuscator = errors.New("invalid obfuscator configuration")
+	ErrIDOutOfRange      = errors.New("id out of obfuscation range")
+)
+
+// Obfuscator is a keyed bijection on the integers [0, 2^bits). It is a
+// balanced Feistel network whose round function is HMAC-SHA256, so IDs map to
+// random-looking values without collisions and can be mapped back with the
+// same key.
+type Obfuscator struct {
+	key      []byte
+	bits     uint
+	halfBits uint
+	halfMask uint64
+}
+
+// NewObfuscator creates an obfuscator over bits-wide integers. bits must be
+// even and between 16 and 62, and bounds the largest ID that can be encoded.
+func NewObfuscator(key []byte, bits uint) (*Obfuscator, error) {
+	if len(key) < 16 {
+		return nil, fmt.Errorf("%w: key must be at least 16 bytes", ErrInvalidObfuscator)
+	}
+	if bits < 16 || bits > 62 || bits%2 != 0 {
+		return nil, fmt.Errorf("%w: bits must be even and between 16 and 62", ErrInvalidObfuscator)
+	}
+
+	half := bits / 2
+	return &Obfuscator{
+		key:      key,
+		bits:     bits,
+		halfBits: half,
+		halfMask: 1<<half - 1,
+	}, nil
+}
+
+func (o *Obfuscator) Obfuscate(id int64) (int64, error) {
+	if err := o.checkRange(id); err != nil {
+		return 0, err
+	}
+
+	left, right := uint64(id)>>o.halfBits, uint64(id)&o.halfMask
+	for round := 0; round < feistelRounds; round++ {
+		left, right = right, left^o.round(round, right)
+	}
+	return int64(left<<o.halfBits | right), nil
+}
+
+func (o *Obfuscator) Deobfuscate(value int64) (int64, error) {
+	if err := o.checkRange(value); err != nil {
+		return 0, err
+	}
+
+	left, right := uint64(value)>>o.halfBits, uint64(value)&o.halfMask
+	for round := feistelRounds - 1; round >= 0; round-- {
+		left, right = right^o.round(round, left), left
+	}
+	return int64(left<<o.halfBits | right), nil
+}
+
+// Covers reports whether id is narrow enough to be obfuscated.
+func (o *Obfuscator) Covers(id int64) bool {
+	return o.checkRange(id) == nil
+}
+
+func (o *Obfuscator) checkRange(v int64) error {
+	if v < 0 || uint64(v)>>o.bits != 0 {
+		return fmt.Errorf("%w: %d does not fit in %d bits", ErrIDOutOfRange, v, o.bits)
+	}
+	return nil
+}
+
+func (o *Obfuscator) round(round int, half uint64) uint64 {
+	var input [9]byte
+	input[0] = byte(round)
+	binary.BigEndian.PutUint64(input[1:], half)
+
+	mac := hmac.New(sha256.New, o.key)
+	mac.Write(input[:])
+	return binary.BigEndian.Uint64(mac.Sum(nil)) & o.halfMask
+}
@@ -0,0 +1,31 @@
+package shortener
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/RajNykDhulapkar/gotiny/pkg/interfaces"
+)
+
+// RandomShortener generates fixed-length random codes, retrying with a new
+// code while the repository already has one.
+type RandomShortener struct {
+	*candidateShortener
+}
+
+func NewRandomShortener(repository interfaces.URLRepository, config *CandidateConfig) (*RandomShortener, error) {
+	s, err := newCandidateShortener("random", repository, config, randomCandidate)
+	if err != nil {
+		return nil, err
+	}
+	return &RandomShortener{s}, nil
+}
+
+func randomCandidate(_, _, _ string, _ int) (uint64, error) {
+	var buf [8]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return 0, fmt.Errorf("failed to read random bytes: %w", err)
+	}
+	return binary.BigEndian.Uint64(buf[:]), nil
+}
@@ -3,43 +3,193 @@ package shortener
 import (
 	"context"
 	"fmt"
-	"hash/crc32"
+	"math"
+	"strings"
 
+	"github.com/RajNykDhulapkar/gotiny/internals/blocklist"
 	"github.com/RajNykDhulapkar/gotiny/pkg/interfaces"
 )
 
+// maxBlockedSkips bounds how many consecutive IDs may be skipped because
+// their code is blocked.
+const maxBlockedSkips = 100
+
 type Shortener struct {
 	encoder        interfaces.Base62EncoderPort
 	rangeAllocator interfaces.RangeAllocatorPort // Interface from your range allocator module
+	config         *Config
 }
 
 type Config struct {
 	ServiceID string
 	RangeSize int64
+	// ChecksumMinID is the first ID whose code carries an ID-based check
+	// character. Codes for lower IDs were generated with the old URL-based
+	// checksum, which cannot be verified, and are accepted as is.
+	ChecksumMinID int64
+	// Obfuscator, if set, scrambles IDs before encoding so codes are not
+	// sequential. IDs wider than the obfuscator, such as fallback IDs issued
+	// while the range allocator is down, are encoded as is; they cannot
+	// collide with obfuscated values, which are always narrower.
+	Obfuscator *Obfuscator
+	// Encoder overrides the default base62 encoder, e.g. with a custom
+	// alphabet or minimum length.
+	Encoder interfaces.Base62EncoderPort
+	// Blocklist rejects codes that spell reserved or offensive words; their
+	// IDs are skipped.
+	Blocklist *blocklist.Blocklist
+	// RegionPrefix, if set, is a single alphabet character put in front of
+	// every code to mark the region that generated it. Prefixed codes end in
+	// a regional check character, so they never collide with unprefixed codes
+	// for IDs from ChecksumMinID on. Codes issued before ChecksumMinID are
+	// kept apart by padding the ID, which needs the same ChecksumMinID in
+	// every region.
+	RegionPrefix string
+}
+
+// Validate reports settings that would produce codes longer than
+// maxGeneratedLength, which would be mistaken for aliases.
+func (c *Config) Validate() error {
+	var encoder interfaces.Base62EncoderPort = NewBase62Encoder()
+	if c.Encoder != nil {
+		encoder = c.Encoder
+	}
+
+	longest, err := encoder.Encode(math.MaxInt64)
+	if err != nil {
+		return err
+	}
+	if length := len(c.RegionPrefix) + len(longest) + 1; length > maxGeneratedLength {
+		return fmt.Errorf("codes can be %d characters long, more than %d; use a larger alphabet, a smaller minimum length or no region prefix", length, maxGeneratedLength)
+	}
+	if c.RegionPrefix != "" && c.RegionPrefix == encoder.Alphabet()[:1] && c.ChecksumMinID > 0 {
+		return fmt.Errorf("region prefix %q is the alphabet's zero digit, so it cannot keep codes apart from those issued before the checksum min ID", c.RegionPrefix)
+	}
+	return nil
 }
 
 func NewShortener(rangeAllocator interfaces.RangeAllocatorPort, config *Config) *Shortener {
+	if config == nil {
+		config = &Config{}
+	}
+
+	var encoder interfaces.Base62EncoderPort = NewBase62Encoder()
+	if config.Encoder != nil {
+		encoder = config.Encoder
+	}
+
 	return &Shortener{
-		encoder:        NewBase62Encoder(),
+		encoder:        encoder,
 		rangeAllocator: rangeAllocator,
+		config:         config,
+	}
+}
+
+func (s *Shortener) GenerateShortLink(ctx context.Context, domain, originalURL, userID string) (string, error) {
+	for skipped := 0; skipped < maxBlockedSkips; skipped++ {
+		code, err := s.nextCode(ctx)
+		if err != nil {
+			return "", err
+		}
+		if !s.config.Blocklist.IsBlocked(code) {
+			return code, nil
+		}
 	}
+
+	return "", fmt.Errorf("%w: %d consecutive codes were blocked", ErrNoFreeCode, maxBlockedSkips)
 }
 
-func (s *Shortener) GenerateShortLink(ctx context.Context, originalURL string, userID string) (string, error) {
+func (s *Shortener) nextCode(ctx context.Context) (string, error) {
 	// Get next available ID from range allocator
 	id, err := s.rangeAllocator.GetNextID(ctx)
 	if err != nil {
 		return "", fmt.Errorf("failed to get next ID: %w", err)
 	}
 
-	// Calculate checksum from original URL & userID
-	h := crc32.NewIEEE()
-	h.Write([]byte(originalURL))
-	h.Write([]byte(userID))
-	checksum := int64(h.Sum32()) % 62 // Keep within base62 single char
+	if s.config.Obfuscator != nil && s.config.Obfuscator.Covers(id) {
+		if id, err = s.config.Obfuscator.Obfuscate(id); err != nil {
+			return "", fmt.Errorf("failed to obfuscate ID: %w", err)
+		}
+	}
+
+	// Combine: region prefix + encode(id) + check character
+	encoded, err := s.encoder.Encode(id)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode ID: %w", err)
+	}
+	if s.config.RegionPrefix == "" {
+		return encoded + string(checkChar(s.encoder.Alphabet(), encoded)), nil
+	}
+	code, err := s.regionalBody(encoded)
+	if err != nil {
+		return "", err
+	}
+	return code + string(regionalCheckChar(s.encoder.Alphabet(), code)), nil
+}
+
+// regionalBody puts the region prefix in front of encoded. Codes issued
+// before ChecksumMinID end in a check character derived from the URL, which
+// matches the regional one about once per alphabet length; their bodies
+// decode below ChecksumMinID, so encoded is padded with zero digits until the
+// prefixed body decodes to at least ChecksumMinID.
+func (s *Shortener) regionalBody(encoded string) (string, error) {
+	zero := s.encoder.Alphabet()[:1]
+	for {
+		body := s.config.RegionPrefix + encoded
+		if id, err := s.encoder.Decode(body); err != nil || id >= s.config.ChecksumMinID {
+			return body, nil
+		}
+		if len(body)+1 >= maxGeneratedLength {
+			return "", fmt.Errorf("region prefix %q cannot lift %q above the checksum min ID", s.config.RegionPrefix, encoded)
+		}
+		encoded = zero + encoded
+	}
+}
+
+// ValidateShortLink rejects codes that cannot belong to any link: codes with
+// characters no alias or generated code uses, and generated codes whose check
+// character does not match. Well-formed aliases are accepted, and with a
+// region prefix so are codes generated in any region.
+func (s *Shortener) ValidateShortLink(code string) error {
+	alphabet := s.encoder.Alphabet()
+	if s.config.RegionPrefix != "" && IsRegionalCode(alphabet, code) {
+		return nil
+	}
+	if isGeneratedShape(alphabet, code) {
+		if id, err := s.encoder.Decode(code[:len(code)-1]); err == nil && id < s.config.ChecksumMinID {
+			return nil
+		}
+	}
+	return validateCode(alphabet, code)
+}
+
+// DecodeID returns the ID a generated code was built from.
+func (s *Shortener) DecodeID(code string) (int64, error) {
+	alphabet := s.encoder.Alphabet()
+	if !isGeneratedShape(alphabet, code) {
+		return 0, ErrInvalidShortLink
+	}
+
+	body := code[:len(code)-1]
+	if s.config.RegionPrefix == "" {
+		if checkChar(alphabet, body) != code[len(code)-1] {
+			return 0, ErrInvalidShortLink
+		}
+	} else {
+		var ok bool
+		if body, ok = strings.CutPrefix(body, s.config.RegionPrefix); !ok || body == "" || !IsRegionalCode(alphabet, code) {
+			return 0, ErrInvalidShortLink
+		}
+	}
 
-	// Combine: id + encode(checksum)
-	return s.encoder.Encode(id) + s.encoder.Encode(checksum), nil
+	id, err := s.encoder.Decode(body)
+	if err != nil {
+		return 0, err
+	}
+	if s.config.Obfuscator != nil && s.config.Obfuscator.Covers(id) {
+		return s.config.Obfuscator.Deobfuscate(id)
+	}
+	return id, nil
 }
 
 // Base62 test function to demonstrate encoding/decoding
@@ -47,7 +197,7 @@ func (s *Shortener) TestBase62() {
 	testCases := []int64{0, 1, 61, 62, 1000, 999999}
 
 	for _, num := range testCases {
-		encoded := s.encoder.Encode(num)
+		encoded, _ := s.encoder.Encode(num)
 		decoded, err := s.encoder.Decode(encoded)
 
 		fmt.Printf("Number: %d\n", num)
@@ -0,0 +1,162 @@
+package shortener
+
+import (
+	"context"
+	"testing"
+
+	"github.com/RajNykDhulapkar/gotiny/internals/rangeallocator"
+	"github.com/RajNykDhulapkar/gotiny/internals/rangeallocator/fake"
+)
+
+func TestShortenerWithFakeAllocator(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *Config
+	}{
+		{"plain", &Config{}},
+		{"obfuscated", &Config{Obfuscator: newTestObfuscator(t)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := fake.NewServer(fake.DefaultConfig())
+			manager := server.NewRangeManager(t, &rangeallocator.RangeManagerConfig{
+				ServiceID: "test",
+				RangeSize: 50,
+			})
+			s := NewShortener(manager, tt.config)
+
+			ids := make(map[int64]string)
+			for i := 0; i < 120; i++ {
+				code, err := s.GenerateShortLink(context.Background(), "", "https://example.com", "user")
+				if err != nil {
+					t.Fatalf("GenerateShortLink: %v", err)
+				}
+				if err := s.ValidateShortLink(code); err != nil {
+					t.Fatalf("ValidateShortLink(%q): %v", code, err)
+				}
+
+				id, err := s.DecodeID(code)
+				if err != nil {
+					t.Fatalf("DecodeID(%q): %v", code, err)
+				}
+				if other, ok := ids[id]; ok {
+					t.Fatalf("codes %q and %q share ID %d", other, code, id)
+				}
+				ids[id] = code
+			}
+
+			ranges := server.Ranges()
+			if len(ranges) < 3 {
+				t.Fatalf("got %d ranges for 120 IDs with size 50", len(ranges))
+			}
+			for id := range ids {
+				found := false
+				for _, r := range ranges {
+					found = found || (r.StartId <= id && id <= r.EndId)
+				}
+				if !found {
+					t.Fatalf("ID %d is outside every allocated range", id)
+				}
+			}
+		})
+	}
+}
+
+func TestRegionPrefixedCodes(t *testing.T) {
+	ctx := context.Background()
+	plain := NewShortener(&counterAllocator{}, &Config{})
+	prefixed := NewShortener(&counterAllocator{}, &Config{RegionPrefix: "1"})
+
+	plainCodes := make(map[string]bool)
+	for i := 0; i < 5000; i++ {
+		code, err := plain.GenerateShortLink(ctx, "", "https://example.com", "user")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if IsRegionalCode(charset, code) {
+			t.Fatalf("unprefixed code %q looks regional", code)
+		}
+		plainCodes[code] = true
+	}
+
+	for i := int64(1); i <= 5000; i++ {
+		code, err := prefixed.GenerateShortLink(ctx, "", "https://example.com", "user")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if plainCodes[code] {
+			t.Fatalf("prefixed code %q for ID %d collides with an unprefixed code", code, i)
+		}
+		if !IsRegionalCode(charset, code) {
+			t.Fatalf("prefixed code %q is not regional", code)
+		}
+		if err := prefixed.ValidateShortLink(code); err != nil {
+			t.Fatalf("ValidateShortLink(%q): %v", code, err)
+		}
+		if err := plain.ValidateShortLink(code); err == nil {
+			t.Fatalf("a shortener without prefix accepted %q", code)
+		}
+		if id, err := prefixed.DecodeID(code); err != nil || id != i {
+			t.Fatalf("DecodeID(%q) = %d, %v; want %d", code, id, err, i)
+		}
+	}
+}
+
+// TestRegionPrefixedCodesAfterLegacyCodes checks that prefixed codes never
+// share a body with codes issued before ChecksumMinID, whose URL-based check
+// character may equal the regional one.
+func TestRegionPrefixedCodesAfterLegacyCodes(t *testing.T) {
+	const checksumMinID = 5000000
+	ctx := context.Background()
+	encoder := NewBase62Encoder()
+	prefixed := NewShortener(&counterAllocator{}, &Config{ChecksumMinID: checksumMinID, RegionPrefix: "1"})
+
+	for i := int64(1); i <= 5000; i++ {
+		code, err := prefixed.GenerateShortLink(ctx, "", "https://example.com", "user")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body, err := encoder.Decode(code[:len(code)-1]); err != nil || body < checksumMinID {
+			t.Fatalf("body of %q decodes to %d, %v; want at least %d", code, body, err, checksumMinID)
+		}
+		if err := prefixed.ValidateShortLink(code); err != nil {
+			t.Fatalf("ValidateShortLink(%q): %v", code, err)
+		}
+		if id, err := prefixed.DecodeID(code); err != nil || id != i {
+			t.Fatalf("DecodeID(%q) = %d, %v; want %d", code, id, err, i)
+		}
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	base32, err := NewEncoder("23456789ABCDEFGHJKLMNPQRSTUVWXYZ", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	padded, err := NewEncoder(charset, 13)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		config  *Config
+		wantErr bool
+	}{
+		{"default", &Config{}, false},
+		{"default with prefix", &Config{RegionPrefix: "1"}, false},
+		{"32 characters", &Config{Encoder: base32}, false},
+		{"32 characters with prefix", &Config{Encoder: base32, RegionPrefix: "2"}, true},
+		{"padded to 13", &Config{Encoder: padded}, false},
+		{"padded to 13 with prefix", &Config{Encoder: padded, RegionPrefix: "1"}, true},
+		{"zero digit prefix", &Config{RegionPrefix: "0"}, false},
+		{"zero digit prefix after legacy codes", &Config{RegionPrefix: "0", ChecksumMinID: 1000}, true},
+	}
+
+	for _, tt := range tests {
+		if err := tt.config.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: got %v, want error: %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
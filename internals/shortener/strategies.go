@@ -0,0 +1,91 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/RajNykDhulapkar/gotiny/pkg/interfaces"
+)
+
+const (
+	StrategySequential = "sequential"
+	StrategyObfuscated = "obfuscated"
+	StrategyRandom     = "random"
+	StrategyHash       = "hash"
+)
+
+var ErrUnknownStrategy = errors.New("unknown shortener strategy")
+
+// Strategies is a registry of named short code strategies. It implements
+// interfaces.ShortenerStrategies by delegating to its default strategy.
+type Strategies struct {
+	strategies  map[string]interfaces.ShortenerInterface
+	defaultName string
+}
+
+func NewStrategies() *Strategies {
+	return &Strategies{
+		strategies: make(map[string]interfaces.ShortenerInterface),
+	}
+}
+
+// Register adds a strategy under name. The first registered strategy becomes
+// the default until SetDefault is called.
+func (s *Strategies) Register(name string, strategy interfaces.ShortenerInterface) {
+	if s.defaultName == "" {
+		s.defaultName = name
+	}
+	s.strategies[name] = strategy
+}
+
+func (s *Strategies) SetDefault(name string) error {
+	if _, ok := s.strategies[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownStrategy, name)
+	}
+	s.defaultName = name
+	return nil
+}
+
+// Strategy returns the strategy registered under name, or the default one
+// when name is empty.
+func (s *Strategies) Strategy(name string) (interfaces.ShortenerInterface, error) {
+	if name == "" {
+		name = s.defaultName
+	}
+	strategy, ok := s.strategies[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownStrategy, name)
+	}
+	return strategy, nil
+}
+
+// Names lists the registered strategies in alphabetical order.
+func (s *Strategies) Names() []string {
+	names := make([]string, 0, len(s.strategies))
+	for name := range s.strategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *Strategies) GenerateShortLink(ctx context.Context, domain, originalURL, userID string) (string, error) {
+	strategy, err := s.Strategy("")
+	if err != nil {
+		return "", err
+	}
+	return strategy.GenerateShortLink(ctx, domain, originalURL, userID)
+}
+
+// ValidateShortLink accepts a code if any registered strategy could have
+// produced it.
+func (s *Strategies) ValidateShortLink(code string) error {
+	for _, strategy := range s.strategies {
+		if strategy.ValidateShortLink(code) == nil {
+			return nil
+		}
+	}
+	return ErrInvalidShortLink
+}
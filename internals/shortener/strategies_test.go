@@ -0,0 +1,290 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/RajNykDhulapkar/gotiny-range-allocator/pkg/pb"
+	"github.com/RajNykDhulapkar/gotiny/pkg/interfaces"
+)
+
+// counterAllocator hands out consecutive IDs starting after next.
+type counterAllocator struct {
+	next atomic.Int64
+}
+
+func (a *counterAllocator) GetNextID(context.Context) (int64, error) {
+	return a.next.Add(1), nil
+}
+
+func (a *counterAllocator) GetCurrentRange() *pb.Range {
+	return nil
+}
+
+// stubRepository reports the first takenLookups codes looked up as taken.
+// Methods other than FindByShortURL are not used by the strategies.
+type stubRepository struct {
+	interfaces.URLRepository
+	takenLookups int
+	lookups      []string
+}
+
+func (r *stubRepository) FindByShortURL(_ context.Context, _, shortURL string) (*interfaces.URLEntity, error) {
+	r.lookups = append(r.lookups, shortURL)
+	if len(r.lookups) <= r.takenLookups {
+		return &interfaces.URLEntity{ShortURL: shortURL}, nil
+	}
+	return nil, nil
+}
+
+func newTestObfuscator(t *testing.T) *Obfuscator {
+	t.Helper()
+	obfuscator, err := NewObfuscator([]byte("0123456789abcdef"), 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return obfuscator
+}
+
+// mutateCheckChar replaces the check character of code with another
+// character from alphabet.
+func mutateCheckChar(alphabet, code string) string {
+	last := code[len(code)-1]
+	replacement := alphabet[0]
+	if replacement == last {
+		replacement = alphabet[1]
+	}
+	return code[:len(code)-1] + string(replacement)
+}
+
+func TestStrategyConformance(t *testing.T) {
+	lookalikeFree := "23456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnpqrstuvwxyz"
+
+	tests := []struct {
+		name     string
+		alphabet string
+		strategy func(t *testing.T) interfaces.ShortenerInterface
+	}{
+		{
+			name:     StrategySequential,
+			alphabet: charset,
+			strategy: func(t *testing.T) interfaces.ShortenerInterface {
+				return NewShortener(&counterAllocator{}, &Config{})
+			},
+		},
+		{
+			name:     StrategySequential + "/custom alphabet",
+			alphabet: lookalikeFree,
+			strategy: func(t *testing.T) interfaces.ShortenerInterface {
+				encoder, err := NewEncoder(lookalikeFree, 6)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return NewShortener(&counterAllocator{}, &Config{Encoder: encoder})
+			},
+		},
+		{
+			name:     StrategyObfuscated,
+			alphabet: charset,
+			strategy: func(t *testing.T) interfaces.ShortenerInterface {
+				return NewShortener(&counterAllocator{}, &Config{Obfuscator: newTestObfuscator(t)})
+			},
+		},
+		{
+			name:     StrategyRandom,
+			alphabet: charset,
+			strategy: func(t *testing.T) interfaces.ShortenerInterface {
+				s, err := NewRandomShortener(&stubRepository{}, DefaultCandidateConfig())
+				if err != nil {
+					t.Fatal(err)
+				}
+				return s
+			},
+		},
+		{
+			name:     StrategyHash,
+			alphabet: charset,
+			strategy: func(t *testing.T) interfaces.ShortenerInterface {
+				s, err := NewHashShortener(&stubRepository{}, DefaultCandidateConfig())
+				if err != nil {
+					t.Fatal(err)
+				}
+				return s
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			strategy := tt.strategy(t)
+			ctx := context.Background()
+
+			seen := make(map[string]bool)
+			for i := 0; i < 200; i++ {
+				code, err := strategy.GenerateShortLink(ctx, "", "https://example.com/"+string(rune('a'+i%26)), "user")
+				if err != nil {
+					t.Fatalf("GenerateShortLink: %v", err)
+				}
+				if !isGeneratedShape(tt.alphabet, code) {
+					t.Fatalf("code %q does not have the generated shape", code)
+				}
+				if err := ValidateAlias(code); err == nil {
+					t.Fatalf("code %q is also a valid alias", code)
+				}
+				if err := strategy.ValidateShortLink(code); err != nil {
+					t.Fatalf("ValidateShortLink(%q): %v", code, err)
+				}
+				if mutated := mutateCheckChar(tt.alphabet, code); strategy.ValidateShortLink(mutated) == nil {
+					t.Fatalf("ValidateShortLink accepted %q, a mutation of %q", mutated, code)
+				}
+				seen[code] = true
+			}
+
+			// Purely alphanumeric aliases of 13 or 14 characters were allowed
+			// before custom alphabets and must keep resolving.
+			for _, alias := range []string{"SpringSale2024", "newsletterMay"} {
+				if err := strategy.ValidateShortLink(alias); err != nil {
+					t.Fatalf("ValidateShortLink(%q) rejected a legacy alias: %v", alias, err)
+				}
+			}
+
+			// The hash strategy maps each of the 26 destinations to one code.
+			want := 200
+			if tt.name == StrategyHash {
+				want = 26
+			}
+			if len(seen) != want {
+				t.Fatalf("got %d distinct codes, want %d", len(seen), want)
+			}
+		})
+	}
+}
+
+func TestStrategyCollisionRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy func(repository interfaces.URLRepository) (interfaces.ShortenerInterface, error)
+	}{
+		{
+			name: StrategyRandom,
+			strategy: func(repository interfaces.URLRepository) (interfaces.ShortenerInterface, error) {
+				return NewRandomShortener(repository, DefaultCandidateConfig())
+			},
+		},
+		{
+			name: StrategyHash,
+			strategy: func(repository interfaces.URLRepository) (interfaces.ShortenerInterface, error) {
+				return NewHashShortener(repository, DefaultCandidateConfig())
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			maxAttempts := DefaultCandidateConfig().MaxAttempts
+
+			repository := &stubRepository{takenLookups: 2}
+			strategy, err := tt.strategy(repository)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			code, err := strategy.GenerateShortLink(ctx, "", "https://example.com", "user")
+			if err != nil {
+				t.Fatalf("GenerateShortLink: %v", err)
+			}
+			if len(repository.lookups) != 3 {
+				t.Fatalf("got %d lookups, want 3", len(repository.lookups))
+			}
+			if code != repository.lookups[2] {
+				t.Fatalf("got %q, want the first free candidate %q", code, repository.lookups[2])
+			}
+			for _, taken := range repository.lookups[:2] {
+				if code == taken {
+					t.Fatalf("got taken code %q", code)
+				}
+			}
+
+			repository = &stubRepository{takenLookups: maxAttempts}
+			if strategy, err = tt.strategy(repository); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := strategy.GenerateShortLink(ctx, "", "https://example.com", "user"); !errors.Is(err, ErrNoFreeCode) {
+				t.Fatalf("got %v with every candidate taken, want ErrNoFreeCode", err)
+			}
+			if len(repository.lookups) != maxAttempts {
+				t.Fatalf("got %d lookups, want %d", len(repository.lookups), maxAttempts)
+			}
+		})
+	}
+}
+
+func TestHashStrategyIsDeterministic(t *testing.T) {
+	ctx := context.Background()
+	first, err := NewHashShortener(&stubRepository{}, DefaultCandidateConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := NewHashShortener(&stubRepository{}, DefaultCandidateConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := first.GenerateShortLink(ctx, "", "https://example.com", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := second.GenerateShortLink(ctx, "", "https://example.com", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a != b {
+		t.Fatalf("got %q and %q for the same destination", a, b)
+	}
+
+	c, err := first.GenerateShortLink(ctx, "", "https://example.com", "other-user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == c {
+		t.Fatalf("got %q for two users", a)
+	}
+}
+
+func TestStrategiesRegistry(t *testing.T) {
+	strategies := NewStrategies()
+	sequential := NewShortener(&counterAllocator{}, &Config{})
+	random, err := NewRandomShortener(&stubRepository{}, DefaultCandidateConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	strategies.Register(StrategySequential, sequential)
+	strategies.Register(StrategyRandom, random)
+
+	if s, err := strategies.Strategy(""); err != nil || s != sequential {
+		t.Fatalf("default strategy: got %v, %v; want the first registered", s, err)
+	}
+	if err := strategies.SetDefault("unknown"); !errors.Is(err, ErrUnknownStrategy) {
+		t.Fatalf("SetDefault(unknown): got %v, want ErrUnknownStrategy", err)
+	}
+	if err := strategies.SetDefault(StrategyRandom); err != nil {
+		t.Fatal(err)
+	}
+	if s, err := strategies.Strategy(""); err != nil || s != random {
+		t.Fatalf("default strategy after SetDefault: got %v, %v", s, err)
+	}
+
+	code, err := sequential.GenerateShortLink(context.Background(), "", "https://example.com", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := strategies.ValidateShortLink(code); err != nil {
+		t.Fatalf("registry rejected %q from a registered strategy: %v", code, err)
+	}
+	if err := strategies.ValidateShortLink(mutateCheckChar(charset, code)); !errors.Is(err, ErrInvalidShortLink) {
+		t.Fatalf("registry accepted a mutated code: %v", err)
+	}
+}
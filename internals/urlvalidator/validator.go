@@ -0,0 +1,145 @@
+package urlvalidator
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+var (
+	ErrTooLong           = errors.New("url is too long")
+	ErrMalformed         = errors.New("url is malformed")
+	ErrNotAbsolute       = errors.New("url must be absolute")
+	ErrUnsupportedScheme = errors.New("url scheme is not allowed")
+	ErrCredentials       = errors.New("url must not contain credentials")
+	ErrInvalidHost       = errors.New("url host is invalid")
+	ErrSelfReference     = errors.New("url points to a short link domain")
+)
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+type Config struct {
+	AllowedSchemes []string
+	MaxLength      int
+	// BlockedHosts are hosts links may not point to, normally our own short
+	// domains, so a short link can never redirect to another short link.
+	BlockedHosts []string
+}
+
+func DefaultConfig() *Config {
+	return &Config{
+		AllowedSchemes: []string{"http", "https"},
+		MaxLength:      2048,
+	}
+}
+
+type Validator struct {
+	config       *Config
+	blockedHosts map[string]struct{}
+}
+
+func New(config *Config) *Validator {
+	if config == nil {
+		config = DefaultConfig()
+	}
+
+	blockedHosts := make(map[string]struct{}, len(config.BlockedHosts))
+	for _, host := range config.BlockedHosts {
+		blockedHosts[strings.ToLower(host)] = struct{}{}
+	}
+
+	return &Validator{
+		config:       config,
+		blockedHosts: blockedHosts,
+	}
+}
+
+// Normalize validates rawURL and returns its canonical form: lowercase scheme
+// and host, IDN hosts converted to punycode, default ports removed and an
+// empty path replaced by "/".
+func (v *Validator) Normalize(rawURL string) (string, error) {
+	rawURL = strings.TrimSpace(rawURL)
+	if len(rawURL) > v.config.MaxLength {
+		return "", fmt.Errorf("%w: %d characters exceeds the limit of %d", ErrTooLong, len(rawURL), v.config.MaxLength)
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	if u.Scheme == "" || u.Opaque != "" || u.Host == "" {
+		if u.Scheme != "" && !v.schemeAllowed(strings.ToLower(u.Scheme)) {
+			return "", fmt.Errorf("%w: %s", ErrUnsupportedScheme, u.Scheme)
+		}
+		return "", fmt.Errorf("%w: scheme and host are required", ErrNotAbsolute)
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	if !v.schemeAllowed(u.Scheme) {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedScheme, u.Scheme)
+	}
+	if u.User != nil {
+		return "", ErrCredentials
+	}
+
+	host, err := normalizeHost(u.Hostname())
+	if err != nil {
+		return "", err
+	}
+	if _, blocked := v.blockedHosts[host]; blocked {
+		return "", fmt.Errorf("%w: %s", ErrSelfReference, host)
+	}
+
+	switch port := u.Port(); {
+	case port != "" && port != defaultPorts[u.Scheme]:
+		u.Host = net.JoinHostPort(host, port)
+	case strings.Contains(host, ":"):
+		u.Host = "[" + host + "]"
+	default:
+		u.Host = host
+	}
+
+	if u.Path == "" {
+		u.Path = "/"
+	}
+
+	normalized := u.String()
+	if len(normalized) > v.config.MaxLength {
+		return "", fmt.Errorf("%w: %d characters exceeds the limit of %d", ErrTooLong, len(normalized), v.config.MaxLength)
+	}
+	return normalized, nil
+}
+
+func (v *Validator) schemeAllowed(scheme string) bool {
+	for _, allowed := range v.config.AllowedSchemes {
+		if scheme == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeHost(host string) (string, error) {
+	if host == "" {
+		return "", fmt.Errorf("%w: empty host", ErrInvalidHost)
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String(), nil
+	}
+
+	ascii, err := idna.Lookup.ToASCII(strings.TrimSuffix(host, "."))
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrInvalidHost, host)
+	}
+	if !strings.Contains(ascii, ".") {
+		return "", fmt.Errorf("%w: %s is not a fully qualified domain", ErrInvalidHost, host)
+	}
+	return strings.ToLower(ascii), nil
+}
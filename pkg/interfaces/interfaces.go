@@ -2,20 +2,35 @@ package interfaces
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/RajNykDhulapkar/gotiny-range-allocator/pkg/pb"
 	"github.com/gin-gonic/gin"
 )
 
+// ErrShortURLExists is returned by URLRepository.Save when the short URL is
+// already taken on its domain.
+var ErrShortURLExists = errors.New("short url already exists")
+
+// ErrDuplicateURL is returned by URLRepository.Save when the user already has
+// a deduplicated link for the same original URL.
+var ErrDuplicateURL = errors.New("url already shortened")
+
 type HandlerInterface interface {
 	CreateShortURL(c *gin.Context)
 	HandleShortURLRedirect(c *gin.Context)
 	GetURLsByUserID(c *gin.Context)
+	ResolveShortURL(c *gin.Context)
+	UpdateShortURL(c *gin.Context)
+	DisableShortURL(c *gin.Context)
+	EnableShortURL(c *gin.Context)
+	DeleteShortURL(c *gin.Context)
 }
 
 type Base62EncoderPort interface {
-	Encode(num int64) string
+	Alphabet() string
+	Encode(num int64) (string, error)
 	Decode(encoded string) (int64, error)
 }
 
@@ -32,29 +47,86 @@ type URLEntity struct {
 	CreatedAt   time.Time `bson:"created_at"`
 	UpdatedAt   time.Time `bson:"updated_at"`
 	ClickCount  int64     `bson:"click_count"`
+	// Domain is the key of the short domain the link belongs to; empty for
+	// the default domain.
+	Domain string `bson:"domain,omitempty"`
+	// Zero means the server-wide default redirect status applies.
+	RedirectStatus int `bson:"redirect_status,omitempty"`
+	// Optional lifetime limits; zero values mean unlimited.
+	NotBefore *time.Time `bson:"not_before,omitempty"`
+	ExpiresAt *time.Time `bson:"expires_at,omitempty"`
+	MaxClicks int64      `bson:"max_clicks,omitempty"`
+	Disabled  bool       `bson:"disabled,omitempty"`
+	// ExhaustedAt is when the link used up its MaxClicks. Like ExpiresAt, it
+	// starts the retention period after which the link is purged.
+	ExhaustedAt *time.Time `bson:"exhausted_at,omitempty"`
+	// Dedup marks links created in dedup mode; OriginalURL is normalized and
+	// unique per user among them.
+	Dedup bool `bson:"dedup,omitempty"`
+}
+
+// URLUpdate lists the fields of a URLEntity to change; nil fields are left
+// untouched. ClearNotBefore and ClearExpiresAt remove those limits, and
+// ClearDedup takes the link out of dedup mode so a new one can replace it.
+type URLUpdate struct {
+	OriginalURL    *string
+	RedirectStatus *int
+	NotBefore      *time.Time
+	ExpiresAt      *time.Time
+	MaxClicks      *int64
+	Disabled       *bool
+	ClearNotBefore bool
+	ClearExpiresAt bool
+	ClearDedup     bool
 }
 
 type ShortenerInterface interface {
-	GenerateShortLink(ctx context.Context, originalURL string, userID string) (string, error)
+	GenerateShortLink(ctx context.Context, domain, originalURL, userID string) (string, error)
+	// ValidateShortLink reports an error for codes that cannot belong to any
+	// link, so they can be rejected without a lookup.
+	ValidateShortLink(code string) error
+}
+
+// ShortenerStrategies is a ShortenerInterface backed by several named short
+// code strategies.
+type ShortenerStrategies interface {
+	ShortenerInterface
+	// Strategy returns the named strategy, or the default one for "".
+	Strategy(name string) (ShortenerInterface, error)
 }
 
 type URLRepository interface {
 	Save(ctx context.Context, url *URLEntity) error
-	FindByShortURL(ctx context.Context, shortURL string) (*URLEntity, error)
-	FindByOriginalURL(ctx context.Context, originalURL string) (*URLEntity, error)
-	IncrementClickCount(ctx context.Context, shortURL string) error
+	FindByShortURL(ctx context.Context, domain, shortURL string) (*URLEntity, error)
+	// FindByOriginalURL looks up the user's deduplicated link for originalURL
+	// on domain.
+	FindByOriginalURL(ctx context.Context, domain, userID, originalURL string) (*URLEntity, error)
+	IncrementClickCount(ctx context.Context, domain, shortURL string) error
+	// IncrementClickCountBelow increments the click count only while it is
+	// below maxClicks and reports whether it did.
+	IncrementClickCountBelow(ctx context.Context, domain, shortURL string, maxClicks int64) (bool, error)
 	FindByUserID(ctx context.Context, userID string) ([]*URLEntity, error)
+	// HasURLs reports whether any link has been saved.
+	HasURLs(ctx context.Context) (bool, error)
+	// Update applies update to the link owned by userID and returns the
+	// updated entity, or nil if there is no such link.
+	Update(ctx context.Context, domain, shortURL, userID string, update *URLUpdate) (*URLEntity, error)
+	// Delete removes the link owned by userID and reports whether it existed.
+	Delete(ctx context.Context, domain, shortURL, userID string) (bool, error)
 	Close(ctx context.Context) error
 }
 
 type CachePort interface {
 	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error
+	// SetNX sets key only if it does not exist yet and reports whether it did.
+	SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error)
 	Get(ctx context.Context, key string) (string, error)
 	Delete(ctx context.Context, key string) error
 	Close() error
 }
 
 type UrlCache interface {
-	SaveUrlMapping(ctx context.Context, shortUrl, originalUrl string, duration time.Duration) error
-	GetOriginalUrl(ctx context.Context, shortUrl string) (string, error)
+	SaveUrlMapping(ctx context.Context, domain, shortUrl string, url *URLEntity, duration time.Duration) error
+	GetUrlMapping(ctx context.Context, domain, shortUrl string) (*URLEntity, error)
+	DeleteUrlMapping(ctx context.Context, domain, shortUrl string) error
 }
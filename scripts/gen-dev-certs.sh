@@ -0,0 +1,35 @@
+#!/bin/sh
+# Generates a throwaway CA plus server and client certificates for trying out
+# mTLS between gotiny and the range allocator locally. Never use in production.
+set -e
+
+OUT=${1:-certs}
+SERVER_NAME=${SERVER_NAME:-range-allocator}
+DAYS=${DAYS:-30}
+
+mkdir -p "$OUT"
+cd "$OUT"
+
+openssl req -x509 -newkey ec -pkeyopt ec_paramgen_curve:P-256 -nodes \
+  -keyout ca.key -out ca.crt -days "$DAYS" -subj "/CN=gotiny dev CA"
+
+issue() {
+  name=$1
+  subject=$2
+  ext=$3
+  openssl req -newkey ec -pkeyopt ec_paramgen_curve:P-256 -nodes \
+    -keyout "$name.key" -out "$name.csr" -subj "/CN=$subject"
+  printf '%s\n' "$ext" >"$name.ext"
+  openssl x509 -req -in "$name.csr" -CA ca.crt -CAkey ca.key -CAcreateserial \
+    -out "$name.crt" -days "$DAYS" -extfile "$name.ext"
+  rm "$name.csr" "$name.ext"
+}
+
+issue server "$SERVER_NAME" "subjectAltName=DNS:$SERVER_NAME,DNS:localhost,IP:127.0.0.1
+extendedKeyUsage=serverAuth"
+issue client gotiny "extendedKeyUsage=clientAuth"
+
+echo "Wrote CA, server and client certificates to $OUT:"
+echo "  RANGE_ALLOCATOR_TLS_CA_FILE=$OUT/ca.crt"
+echo "  RANGE_ALLOCATOR_TLS_CERT_FILE=$OUT/client.crt"
+echo "  RANGE_ALLOCATOR_TLS_KEY_FILE=$OUT/client.key"